@@ -0,0 +1,46 @@
+package kvwriter
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// alignPair pads the pair of key starting at offset start of buf to its column width.
+func (w KeyValueWriter) alignPair(buf *bytes.Buffer, key string, start int) {
+	var width = visibleWidth(buf.Bytes()[start:])
+
+	var column = w.AlignWidth
+	if column == 0 && w.state != nil {
+		w.state.mu.Lock()
+		column = w.state.alignWidths[key]
+		if width > column {
+			column = width
+			w.state.alignWidths[key] = width
+		}
+		w.state.mu.Unlock()
+	}
+
+	if width < column {
+		buf.WriteString(strings.Repeat(" ", column-width))
+	}
+}
+
+// visibleWidth returns the number of runes in b skipping ANSI escape sequences.
+func visibleWidth(b []byte) int {
+	var n int
+	for i := 0; i < len(b); {
+		if b[i] == '\x1b' && i+1 < len(b) && b[i+1] == '[' {
+			i += 2
+			for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+		n++
+	}
+	return n
+}
@@ -0,0 +1,118 @@
+package kvwriter
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned by writes to a closed AsyncWriter.
+var ErrClosed = errors.New("kvwriter: writer closed")
+
+// AsyncPolicy defines what an AsyncWriter does when its queue is full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock blocks the write until there is room in the queue.
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropOldest drops the oldest queued line to make room.
+	AsyncDropOldest
+	// AsyncDropNewest drops the written line.
+	AsyncDropNewest
+)
+
+// AsyncWriter queues the written lines in a bounded queue and writes them to the
+// underlying writer from a background goroutine, so slow sinks do not stall the writing
+// goroutines. Use it as Out of a KeyValueWriter and Close it to drain the queue.
+type AsyncWriter struct {
+	out    io.Writer
+	policy AsyncPolicy
+	queue  chan []byte
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	dropped atomic.Uint64
+	errOnce sync.Once
+	err     error
+}
+
+// NewAsyncWriter creates an AsyncWriter writing to out with a queue of size lines.
+func NewAsyncWriter(out io.Writer, size int, policy AsyncPolicy) *AsyncWriter {
+	if size < 1 {
+		size = 1
+	}
+	a := &AsyncWriter{
+		out:    out,
+		policy: policy,
+		queue:  make(chan []byte, size),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	for p := range a.queue {
+		if _, err := a.out.Write(p); err != nil {
+			a.errOnce.Do(func() { a.err = err })
+		}
+	}
+}
+
+// Write queues a copy of p according to the policy. It returns ErrClosed after Close.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return 0, ErrClosed
+	}
+
+	var line = append([]byte(nil), p...)
+	switch a.policy {
+	case AsyncDropNewest:
+		select {
+		case a.queue <- line:
+		default:
+			a.dropped.Add(1)
+		}
+	case AsyncDropOldest:
+		for {
+			select {
+			case a.queue <- line:
+				return len(p), nil
+			default:
+			}
+			select {
+			case <-a.queue:
+				a.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		a.queue <- line
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of lines dropped because the queue was full.
+func (a *AsyncWriter) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// Close stops accepting writes and waits until all queued lines are written. It returns
+// the first error of writing to the underlying writer.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	return a.err
+}
@@ -0,0 +1,93 @@
+package kvwriter
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+var _ io.Closer = KeyValueWriter{}
+
+// batching reports whether the output is buffered by FlushSize or FlushInterval.
+func (w KeyValueWriter) batching() bool {
+	return (w.FlushSize > 0 || w.FlushInterval > 0) && w.state != nil
+}
+
+// writeBatch appends buf to the batch, flushing it once FlushSize is reached, and starts
+// the FlushInterval timer.
+func (w KeyValueWriter) writeBatch(buf *bytes.Buffer) error {
+	s := w.state
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	_, _ = buf.WriteTo(&s.batch)
+	if w.FlushSize > 0 && s.batch.Len() >= w.FlushSize {
+		return w.flushLocked()
+	}
+	if w.FlushInterval > 0 && s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(w.FlushInterval, func() {
+			_ = w.Flush()
+		})
+	}
+	return nil
+}
+
+// Flush writes the output buffered by FlushSize or FlushInterval, and the events buffered
+// by OutputTable, to Out.
+func (w KeyValueWriter) Flush() error {
+	if w.state == nil {
+		return nil
+	}
+	if err := w.flushTable(); err != nil {
+		return err
+	}
+	w.state.outMu.Lock()
+	defer w.state.outMu.Unlock()
+	return w.flushLocked()
+}
+
+// flushLocked writes the batch to Out. The caller holds outMu.
+func (w KeyValueWriter) flushLocked() error {
+	s := w.state
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if s.batch.Len() == 0 {
+		return nil
+	}
+
+	var line = s.batch.Bytes()
+	n, err := s.batch.WriteTo(w.Out)
+	s.stats.bytesWritten.Add(uint64(n))
+	if w.Hooks.AfterWrite != nil {
+		w.Hooks.AfterWrite(line, err)
+	}
+	if err != nil {
+		err = w.writeError(line, &SinkError{Output: bytes.Clone(line), Err: err})
+	}
+	s.batch.Reset()
+	return err
+}
+
+// Close ends the output: the summaries of events suppressed by RateLimit and the pending
+// repetitions of Dedup are written and the buffered output is flushed. Out is not closed.
+func (w KeyValueWriter) Close() error {
+	if w.state == nil {
+		return nil
+	}
+
+	if err := w.writeRateSummaries(true); err != nil {
+		return err
+	}
+
+	if n := w.state.endDedup(); n > 0 {
+		var buf bytes.Buffer
+		buf.WriteString("(repeated " + strconv.Itoa(n) + " times)\n")
+		if err := w.writeOut(&buf); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,33 @@
+package kvwriter
+
+// BoolFormat defines how booleans are rendered.
+type BoolFormat int
+
+const (
+	// BoolTrueFalse renders booleans as true and false.
+	BoolTrueFalse BoolFormat = iota
+	// BoolYesNo renders booleans as yes and no.
+	BoolYesNo
+	// BoolCheckMark renders booleans as ✓ and ✗.
+	BoolCheckMark
+	// BoolOneZero renders booleans as 1 and 0.
+	BoolOneZero
+)
+
+var boolStrings = [...][2]string{
+	BoolTrueFalse: {"false", "true"},
+	BoolYesNo:     {"no", "yes"},
+	BoolCheckMark: {"✗", "✓"},
+	BoolOneZero:   {"0", "1"},
+}
+
+// Format returns the rendering of b. Unknown formats render true and false.
+func (f BoolFormat) Format(b bool) string {
+	if f < BoolTrueFalse || f > BoolOneZero {
+		f = BoolTrueFalse
+	}
+	if b {
+		return boolStrings[f][1]
+	}
+	return boolStrings[f][0]
+}
@@ -0,0 +1,25 @@
+package kvwriter
+
+import "testing"
+
+func TestBoolFormat(t *testing.T) {
+	tests := []struct {
+		format  BoolFormat
+		yes, no string
+	}{
+		{BoolTrueFalse, "true", "false"},
+		{BoolYesNo, "yes", "no"},
+		{BoolCheckMark, "✓", "✗"},
+		{BoolOneZero, "1", "0"},
+		{BoolFormat(-1), "true", "false"},
+		{BoolFormat(42), "true", "false"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Format(true); got != tt.yes {
+			t.Errorf("%d: got %q, want %q", tt.format, got, tt.yes)
+		}
+		if got := tt.format.Format(false); got != tt.no {
+			t.Errorf("%d: got %q, want %q", tt.format, got, tt.no)
+		}
+	}
+}
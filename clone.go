@@ -0,0 +1,82 @@
+package kvwriter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Clone returns a copy of the writer sharing no configuration with it, so options applied
+// to the copy, e.g. appending excluded keys, never change the writer. Transformers,
+// samplers, decoders and formatters are shared. A writer created by NewKeyValueWriter is
+// cloned with its own state: alignment widths, rate limits, deduplication, stats and
+// batched output are not shared and Locking does not serialize the writes of the writer
+// and the copy. To share Out between them, use a SyncWriter.
+func (w KeyValueWriter) Clone() KeyValueWriter {
+	c := w
+
+	c.KeysExclude = slices.Clone(w.KeysExclude)
+	c.KeysInclude = slices.Clone(w.KeysInclude)
+	c.KeysExcludeRegex = slices.Clone(w.KeysExcludeRegex)
+	c.KeysIncludeRegex = slices.Clone(w.KeysIncludeRegex)
+	c.FieldsOrder = slices.Clone(w.FieldsOrder)
+	c.Columns = slices.Clone(w.Columns)
+	c.ColumnWidths = slices.Clone(w.ColumnWidths)
+	c.KeysRename = maps.Clone(w.KeysRename)
+	c.KeyTrimPrefixes = slices.Clone(w.KeyTrimPrefixes)
+	c.Pipeline = slices.Clone(w.Pipeline)
+	c.LevelTable = maps.Clone(w.LevelTable)
+	c.LevelColors = maps.Clone(w.LevelColors)
+	c.LevelIconSymbols = maps.Clone(w.LevelIconSymbols)
+	c.RedactValues = slices.Clone(w.RedactValues)
+	c.RedactKeys = slices.Clone(w.RedactKeys)
+	c.HashKeys = slices.Clone(w.HashKeys)
+	c.DurationKeys = maps.Clone(w.DurationKeys)
+	c.ByteSizeKeys = slices.Clone(w.ByteSizeKeys)
+	c.EpochKeys = slices.Clone(w.EpochKeys)
+	c.StackTraceKeys = slices.Clone(w.StackTraceKeys)
+	c.ErrorKeys = slices.Clone(w.ErrorKeys)
+	c.UnitFormatters = maps.Clone(w.UnitFormatters)
+	c.FormatFieldValue = maps.Clone(w.FormatFieldValue)
+
+	if w.Theme != nil {
+		t := *w.Theme
+		t.Levels = maps.Clone(w.Theme.Levels)
+		c.Theme = &t
+	}
+
+	if w.state != nil {
+		c.state = newWriterState()
+	}
+	c.compileFilters()
+	c.compileTemplate()
+	return c
+}
+
+// With returns a clone of the writer with the options applied, e.g. a variant without
+// colors of a shared base configuration. It panics if the options result in an invalid
+// configuration, use WithOptions to handle it.
+func (w KeyValueWriter) With(options ...Option) KeyValueWriter {
+	c, err := w.WithOptions(options...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+// WithOptions returns a clone of the writer with the options applied like With, reporting
+// an invalid configuration as an error.
+func (w KeyValueWriter) WithOptions(options ...Option) (KeyValueWriter, error) {
+	c := w.Clone()
+	for _, opt := range options {
+		opt(&c)
+	}
+	c.detectAutoColor()
+	c.compileFilters()
+	c.compileTemplate()
+
+	if err := c.Validate(); err != nil {
+		return KeyValueWriter{}, fmt.Errorf("kvwriter: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// pollInterval is how often followed files are checked for new lines.
+const pollInterval = 250 * time.Millisecond
+
+// follow renders the last lines of the files and then their new lines until ctx is done.
+// Truncated files are read again from the start and rotated files are reopened by name.
+func follow(ctx context.Context, w kvwriter.KeyValueWriter, names []string, lines int, stderr io.Writer) int {
+	var files = make([]*tailer, 0, len(names))
+	for _, name := range names {
+		if name == "-" {
+			fmt.Fprintf(stderr, "kvw: cannot follow standard input\n")
+			return 2
+		}
+		t, err := openTailer(name, kvwriter.NewStreamWriter(w), lines)
+		if err != nil {
+			fmt.Fprintf(stderr, "kvw: %s\n", err)
+			return 1
+		}
+		defer t.close()
+		files = append(files, t)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var status int
+	for _, t := range files {
+		wg.Add(1)
+		go func(t *tailer) {
+			defer wg.Done()
+			if err := t.run(ctx); err != nil {
+				mu.Lock()
+				fmt.Fprintf(stderr, "kvw: %s\n", err)
+				status = 1
+				mu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+	return status
+}
+
+// tailer follows a single file.
+type tailer struct {
+	name   string
+	s      *kvwriter.StreamWriter
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+}
+
+func openTailer(name string, s *kvwriter.StreamWriter, lines int) (*tailer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	offset, err := lastLinesOffset(f, info.Size(), lines)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return &tailer{name: name, s: s, file: f, info: info, offset: offset}, nil
+}
+
+// run copies new lines of the file until ctx is done.
+func (t *tailer) run(ctx context.Context) error {
+	var ticker = time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.read(); err != nil {
+			return err
+		}
+		if err := t.check(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return t.s.Flush()
+		case <-ticker.C:
+		}
+	}
+}
+
+// read renders everything written to the file since the last read.
+func (t *tailer) read() error {
+	n, err := io.Copy(t.s, t.file)
+	t.offset += n
+	if err != nil {
+		return fmt.Errorf("%s: %s", t.name, err)
+	}
+	return nil
+}
+
+// check reopens the file if it was rotated and rewinds it if it was truncated. A missing
+// file is the window of a rotation and is checked again on the next poll.
+func (t *tailer) check() error {
+	info, err := os.Stat(t.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("%s: %s", t.name, err)
+	}
+
+	if !os.SameFile(info, t.info) {
+		// Lines written to the rotated file before the rename.
+		if err := t.read(); err != nil {
+			return err
+		}
+		_ = t.s.Flush()
+
+		f, err := os.Open(t.name)
+		if err != nil {
+			return fmt.Errorf("%s: %s", t.name, err)
+		}
+		_ = t.file.Close()
+		t.file, t.info, t.offset = f, info, 0
+		return nil
+	}
+
+	if info.Size() < t.offset {
+		_ = t.s.Flush()
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("%s: %s", t.name, err)
+		}
+		t.offset = 0
+	}
+	return nil
+}
+
+func (t *tailer) close() {
+	_ = t.file.Close()
+}
+
+// lastLinesOffset returns the offset of the last n lines of the file of the given size.
+func lastLinesOffset(f *os.File, size int64, n int) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+
+	var buf = make([]byte, 4096)
+	var end = size
+	var newlines int
+	for end > 0 {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			// The newline terminating the last line does not start a line.
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			if newlines++; newlines == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while follow writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollow(t *testing.T) {
+	var name = filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, os.O_CREATE|os.O_WRONLY, `{"n":1}`+"\n"+`{"n":2}`+"\n")
+
+	var out syncBuffer
+	w, err := kvwriter.New(kvwriter.WithOutput(&out), kvwriter.WithLocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var done = make(chan int)
+	var stderr syncBuffer
+	go func() { done <- follow(ctx, w, []string{name}, 1, &stderr) }()
+
+	var want = "n=\"2\"\n"
+	waitOutput(t, &out, want)
+
+	writeFile(t, name, os.O_APPEND|os.O_WRONLY, `{"n":3}`+"\n")
+	want += "n=\"3\"\n"
+	waitOutput(t, &out, want)
+
+	// Truncated files are read again from the start.
+	writeFile(t, name, os.O_TRUNC|os.O_WRONLY, `{"n":4}`+"\n")
+	want += "n=\"4\"\n"
+	waitOutput(t, &out, want)
+
+	// Rotated files are reopened by name.
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, name, os.O_CREATE|os.O_WRONLY, `{"n":5}`+"\n")
+	want += "n=\"5\"\n"
+	waitOutput(t, &out, want)
+
+	cancel()
+	if status := <-done; status != 0 {
+		t.Errorf("got status %d: %s", status, stderr.String())
+	}
+}
+
+func TestLastLinesOffset(t *testing.T) {
+	var name = filepath.Join(t.TempDir(), "app.log")
+	var data = "a\nbb\n" + strings.Repeat("c", 5000) + "\nd"
+	writeFile(t, name, os.O_CREATE|os.O_WRONLY, data)
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		lines int
+		want  int64
+	}{
+		{0, int64(len(data))},
+		{1, int64(len(data) - 1)},
+		{2, 5},
+		{3, 2},
+		{10, 0},
+	}
+	for _, tt := range tests {
+		if got, err := lastLinesOffset(f, int64(len(data)), tt.lines); err != nil || got != tt.want {
+			t.Errorf("lastLinesOffset(%d) = %d, %v, want %d", tt.lines, got, err, tt.want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name string, flag int, data string) {
+	t.Helper()
+	f, err := os.OpenFile(name, flag, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitOutput waits until out contains want, failing the test after a few poll intervals.
+func waitOutput(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * pollInterval); time.Now().Before(deadline); {
+		if out.String() == want {
+			return
+		}
+		time.Sleep(pollInterval / 10)
+	}
+	t.Fatalf("got %q, want %q", out.String(), want)
+}
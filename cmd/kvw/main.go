@@ -0,0 +1,227 @@
+// Command kvw renders JSON logs read from files or standard input as key-value lines:
+//
+//	kubectl logs deploy/api | kvw -level warn -exclude 'http.headers.*'
+//
+// Lines that are not JSON are written unchanged. With -f, the files are followed like
+// tail -F, also across truncation and rotation:
+//
+//	kvw -f -n 100 /var/log/app.log
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	kvwriter "github.com/milesich/kv-writer"
+	"github.com/milesich/kv-writer/kvconfig"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// config holds the command-line flags.
+type config struct {
+	file       string
+	set        map[string]bool
+	color      string
+	include    string
+	exclude    string
+	order      string
+	delimiter  string
+	separator  string
+	level      string
+	align      bool
+	logfmt     bool
+	timeLayout string
+	follow     bool
+	lines      int
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var cfg config
+	fs := flag.NewFlagSet("kvw", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kvw [flags] [file ...]\n\n")
+		fmt.Fprintf(fs.Output(), "Renders JSON logs read from the files, or standard input if none or -, as key-value lines.\n")
+		fmt.Fprintf(fs.Output(), "With -f, renders new lines of the files as they are written.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.file, "config", "", "configuration `file` in JSON, YAML or TOML, overridden by the flags")
+	fs.StringVar(&cfg.color, "color", "auto", "colorize the output: auto, always or never")
+	fs.StringVar(&cfg.include, "include", "", "comma-separated `keys` to display, glob patterns allowed")
+	fs.StringVar(&cfg.exclude, "exclude", "", "comma-separated `keys` to hide, glob patterns allowed")
+	fs.StringVar(&cfg.order, "order", "", "comma-separated `keys` written first, in the given order")
+	fs.StringVar(&cfg.delimiter, "delimiter", " ", "delimiter between pairs")
+	fs.StringVar(&cfg.separator, "separator", "=", "separator between keys and values")
+	fs.StringVar(&cfg.level, "level", "", "minimum `level` of the displayed events, e.g. info")
+	fs.BoolVar(&cfg.align, "align", false, "align the values in columns")
+	fs.BoolVar(&cfg.logfmt, "logfmt", false, "write strictly valid logfmt")
+	fs.StringVar(&cfg.timeLayout, "time-layout", "", "Go `layout` of the rendered timestamps, e.g. 15:04:05")
+	fs.BoolVar(&cfg.follow, "f", false, "follow the files, rendering new lines as they are written")
+	fs.BoolVar(&cfg.follow, "follow", false, "same as -f")
+	fs.IntVar(&cfg.lines, "n", 10, "number of last lines rendered before following the files")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	cfg.set = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		cfg.set[f.Name] = true
+	})
+
+	var files = fs.Args()
+	var following = cfg.follow && len(files) > 0
+
+	options, err := cfg.options()
+	var w kvwriter.KeyValueWriter
+	if err == nil {
+		// Lines of several followed files are written concurrently.
+		options = append(options, kvwriter.WithOutput(stdout), kvwriter.WithLocking(following))
+		w, err = kvwriter.New(options...)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "kvw: %s\n", err)
+		return 2
+	}
+
+	if following {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return closeWriter(w, follow(ctx, w, files, cfg.lines, stderr), stderr)
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	s := kvwriter.NewStreamWriter(w)
+
+	var status int
+	for _, name := range files {
+		if err := render(s, name, stdin); err != nil {
+			fmt.Fprintf(stderr, "kvw: %s\n", err)
+			status = 1
+		}
+	}
+	return closeWriter(w, status, stderr)
+}
+
+// closeWriter closes w, writing the output it buffered, e.g. the rows of a table, and
+// returns status, or 1 if closing fails.
+func closeWriter(w kvwriter.KeyValueWriter, status int, stderr io.Writer) int {
+	if err := w.Close(); err != nil {
+		fmt.Fprintf(stderr, "kvw: %s\n", err)
+		return 1
+	}
+	return status
+}
+
+// options converts the configuration file, the KVWRITER_* environment variables and the
+// flags into writer options, each overriding the previous ones.
+func (cfg config) options() ([]kvwriter.Option, error) {
+	var options = []kvwriter.Option{
+		kvwriter.WithPassThroughInvalid(""),
+		kvwriter.WithColorize(kvwriter.ColorAuto),
+	}
+
+	if cfg.file != "" {
+		c, err := kvconfig.ReadConfig(cfg.file)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := c.Options()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, opts...)
+	}
+	options = append(options, kvwriter.FromEnv())
+
+	if cfg.set["delimiter"] {
+		options = append(options, kvwriter.WithPairsSeparator(cfg.delimiter))
+	}
+	if cfg.set["separator"] {
+		options = append(options, kvwriter.WithKeyValueSeparator(cfg.separator))
+	}
+	if cfg.set["logfmt"] {
+		options = append(options, kvwriter.WithLogfmtMode(cfg.logfmt))
+	}
+	if cfg.set["align"] {
+		options = append(options, kvwriter.WithAlignValues(cfg.align, 0))
+	}
+
+	if cfg.set["color"] {
+		switch cfg.color {
+		case "auto":
+			options = append(options, kvwriter.WithColorize(kvwriter.ColorAuto))
+		case "always":
+			options = append(options, kvwriter.WithColorize(kvwriter.ColorAlways))
+		case "never":
+			options = append(options, kvwriter.WithColorize(kvwriter.ColorNever))
+		default:
+			return nil, fmt.Errorf("unknown color mode %q", cfg.color)
+		}
+	}
+
+	if keys := splitList(cfg.include); len(keys) > 0 {
+		options = append(options, kvwriter.WithKeysInclude(keys...))
+	}
+	if keys := splitList(cfg.exclude); len(keys) > 0 {
+		options = append(options, kvwriter.WithKeysExclude(keys...))
+	}
+	if keys := splitList(cfg.order); len(keys) > 0 {
+		options = append(options, kvwriter.WithFieldsOrder(keys...))
+	}
+
+	if cfg.level != "" {
+		l, ok := kvwriter.ParseLevel(cfg.level)
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", cfg.level)
+		}
+		options = append(options, kvwriter.WithMinLevel(l))
+	}
+
+	if cfg.timeLayout != "" {
+		options = append(options, kvwriter.WithTimeLayout(cfg.timeLayout))
+	}
+	return options, nil
+}
+
+// render writes the events of the file name, or stdin if name is "-".
+func render(s *kvwriter.StreamWriter, name string, stdin io.Reader) error {
+	var r = stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if _, err := io.Copy(s, r); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
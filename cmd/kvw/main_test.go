@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const input = `{"time":"2024-01-02T03:04:05Z","level":"info","msg":"start","pid":1}
+not json
+{"time":"2024-01-02T03:04:06Z","level":"error","msg":"failed","pid":1,"code":500}
+`
+
+func TestRun(t *testing.T) {
+	var dir = t.TempDir()
+	var file = filepath.Join(dir, "app.log")
+	if err := os.WriteFile(file, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var conf = filepath.Join(dir, "kvw.yaml")
+	if err := os.WriteFile(conf, []byte("keys_exclude: [pid]\nkeys_rename: {msg: message}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "stdin",
+			want: "level=\"info\" msg=\"start\" pid=\"1\" time=\"2024-01-02T03:04:05Z\"\n" +
+				"not json\n" +
+				"code=\"500\" level=\"error\" msg=\"failed\" pid=\"1\" time=\"2024-01-02T03:04:06Z\"\n",
+		},
+		{
+			name: "filters",
+			args: []string{"-level", "warn", "-include", "level,msg,code", "-order", "msg", "-", file},
+			want: "not json\n" +
+				"msg=\"failed\" code=\"500\" level=\"error\"\n" +
+				"not json\n" +
+				"msg=\"failed\" code=\"500\" level=\"error\"\n",
+		},
+		{
+			name: "separators",
+			args: []string{"-exclude", "time,pid", "-delimiter", ", ", "-separator", ": ", "-time-layout", "15:04:05", file},
+			want: "level: \"info\", msg: \"start\"\n" +
+				"not json\n" +
+				"code: \"500\", level: \"error\", msg: \"failed\"\n",
+		},
+		{
+			name: "logfmt",
+			args: []string{"-logfmt", "-align", "-include", "level,msg", file, file},
+			want: "level=info msg=start\n" +
+				"not json\n" +
+				"level=error msg=failed\n" +
+				"level=info  msg=start\n" +
+				"not json\n" +
+				"level=error msg=failed\n",
+		},
+		{
+			name: "config",
+			args: []string{"-config", conf, "-exclude", "time", file},
+			want: "level=\"info\" message=\"start\"\n" +
+				"not json\n" +
+				"code=\"500\" level=\"error\" message=\"failed\"\n",
+		},
+		{
+			name: "time layout",
+			args: []string{"-include", "time", "-time-layout", "15:04:05", "-color", "never", file},
+			want: "time=\"03:04:05\"\nnot json\ntime=\"03:04:06\"\n",
+		},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(tt.args, strings.NewReader(input), &stdout, &stderr)
+		if status != 0 || stderr.Len() > 0 {
+			t.Errorf("%s: status %d, stderr %q", tt.name, status, stderr.String())
+		}
+		if got := stdout.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var missing = filepath.Join(t.TempDir(), "missing.log")
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"-h"}, 0},
+		{[]string{"-unknown"}, 2},
+		{[]string{"-level", "verbose"}, 2},
+		{[]string{"-color", "sometimes"}, 2},
+		{[]string{"-config", "kvw.ini"}, 2},
+		{[]string{"-f", "-"}, 2},
+		{[]string{missing}, 1},
+		{[]string{"-f", missing}, 1},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if status := run(tt.args, strings.NewReader(""), &stdout, &stderr); status != tt.status {
+			t.Errorf("%q: got status %d, want %d", tt.args, status, tt.status)
+		}
+		if tt.status != 0 && stderr.Len() == 0 {
+			t.Errorf("%q: no error written", tt.args)
+		}
+	}
+}
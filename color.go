@@ -0,0 +1,155 @@
+package kvwriter
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ColorMode defines when the output is colorized.
+type ColorMode int
+
+const (
+	// ColorNever writes plain output without ANSI escape sequences.
+	ColorNever ColorMode = iota
+	// ColorAlways colorizes the output.
+	ColorAlways
+	// ColorAuto colorizes the output if Out is a terminal. The NO_COLOR environment
+	// variable disables colors and FORCE_COLOR enables them regardless of the terminal.
+	ColorAuto
+)
+
+// Color is the parameter of an ANSI SGR escape sequence, e.g. "31" for red or "1;31"
+// for bold red. An empty Color leaves the text unchanged.
+type Color string
+
+// Basic ANSI colors and attributes.
+const (
+	ColorReset   Color = "0"
+	ColorBold    Color = "1"
+	ColorDim     Color = "2"
+	ColorRed     Color = "31"
+	ColorGreen   Color = "32"
+	ColorYellow  Color = "33"
+	ColorBlue    Color = "34"
+	ColorMagenta Color = "35"
+	ColorCyan    Color = "36"
+	ColorWhite   Color = "37"
+)
+
+// DefaultLevelColors maps level names to the colors used when LevelColors is nil.
+var DefaultLevelColors = map[string]Color{
+	"trace": ColorMagenta,
+	"debug": ColorBlue,
+	"info":  ColorGreen,
+	"warn":  ColorYellow,
+	"error": ColorRed,
+	"fatal": ColorBold + ";" + ColorRed,
+	"panic": ColorBold + ";" + ColorRed,
+}
+
+// Colored wraps s in the ANSI escape sequence of c.
+func Colored(s string, c Color) string {
+	if c == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + string(c) + "m" + s + "\x1b[0m"
+}
+
+// ColoredFormatter wraps the output of f in the ANSI escape sequence of c.
+func ColoredFormatter(f Formatter, c Color) Formatter {
+	return func(i interface{}) string {
+		return Colored(f(i), c)
+	}
+}
+
+// colorEnabled reports whether the output is colorized.
+func (w KeyValueWriter) colorEnabled() bool {
+	switch w.Colorize {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		if w.autoColor != 0 {
+			return w.autoColor > 0
+		}
+		return detectColor(w.Out)
+	default:
+		return false
+	}
+}
+
+// detectAutoColor caches the ColorAuto detection for Out.
+func (w *KeyValueWriter) detectAutoColor() {
+	w.autoColor = 0
+	if w.Colorize == ColorAuto {
+		w.autoColor = -1
+		if detectColor(w.Out) {
+			w.autoColor = 1
+		}
+	}
+}
+
+// detectColor reports whether colors should be written to out in ColorAuto mode.
+func detectColor(out io.Writer) bool {
+	if v, ok := os.LookupEnv("FORCE_COLOR"); ok {
+		return v != "0" && v != "false"
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(out)
+}
+
+// isTerminal reports whether out is a file referring to a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// keyColor returns the color of keys.
+func (w KeyValueWriter) keyColor() Color {
+	if w.Theme != nil {
+		return w.Theme.Key
+	}
+	return w.KeyColor
+}
+
+// levelColor returns the color for the level value. Levels are matched case-insensitively
+// and by their abbreviation, so "WRN" and "warning" both match "warn".
+func (w KeyValueWriter) levelColor(level string) Color {
+	var colors = w.LevelColors
+	if w.Theme != nil {
+		colors = w.Theme.Levels
+	} else if colors == nil {
+		colors = DefaultLevelColors
+	}
+
+	if c, ok := colors[strings.ToLower(level)]; ok {
+		return c
+	}
+	abbr := AbbreviateLevel(level)
+	for name, c := range colors {
+		if AbbreviateLevel(name) == abbr {
+			return c
+		}
+	}
+	return ""
+}
+
+// valueColor returns the color for the value of key.
+func (w KeyValueWriter) valueColor(key string, value interface{}) Color {
+	if key != "" && key == w.LevelFieldName {
+		if s, ok := value.(string); ok {
+			return w.levelColor(s)
+		}
+	}
+	if w.Theme != nil {
+		return w.Theme.colorOf(value)
+	}
+	return w.ValueColor
+}
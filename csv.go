@@ -0,0 +1,94 @@
+package kvwriter
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// writeCSV appends the values of Columns to buf as a CSV record, or a TSV record with
+// OutputTSV. Values are formatted like pair values, without quotes and colors, and
+// missing keys are empty. With ExtraColumn, the other pairs are appended as a JSON object.
+// With ColumnsHeader, the column names are written as the first record.
+func (w KeyValueWriter) writeCSV(evt map[string]interface{}, buf *bytes.Buffer) {
+	var comma = byte(',')
+	if w.OutputFormat == OutputTSV {
+		comma = '\t'
+	}
+
+	if w.ColumnsHeader && w.state != nil && w.state.header() {
+		for i, col := range w.Columns {
+			if i > 0 {
+				buf.WriteByte(comma)
+			}
+			w.writeCSVField(buf, col)
+		}
+		if w.ExtraColumn != "" {
+			if len(w.Columns) > 0 {
+				buf.WriteByte(comma)
+			}
+			w.writeCSVField(buf, w.ExtraColumn)
+		}
+		buf.WriteByte('\n')
+	}
+
+	_, fv := w.formatters()
+	for i, col := range w.Columns {
+		if i > 0 {
+			buf.WriteByte(comma)
+		}
+		if value, ok := evt[col]; ok && w.keepKey(col) {
+			s, _ := w.formatValue(col, value, fv)
+			w.writeCSVField(buf, s)
+		}
+	}
+
+	if w.ExtraColumn != "" {
+		if len(w.Columns) > 0 {
+			buf.WriteByte(comma)
+		}
+		w.writeCSVField(buf, w.extraJSON(evt))
+	}
+}
+
+// extraJSON returns the kept pairs of the event not listed in Columns as a JSON object.
+func (w KeyValueWriter) extraJSON(evt map[string]interface{}) string {
+	var pooled = w.sortedKeys(evt)
+	defer putKeys(pooled)
+
+	fk, fv := w.formatters()
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for _, key := range *pooled {
+		if slices.Contains(w.Columns, key) {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		writeJSONString(&b, formatKey(fk, key))
+		b.WriteByte(':')
+		w.writeJSONValue(&b, key, evt[key], fk, fv)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// writeCSVField appends a CSV field, quoted as defined by RFC 4180 if necessary, or a TSV
+// field with tabs, newlines and backslashes escaped with a backslash.
+func (w KeyValueWriter) writeCSVField(buf *bytes.Buffer, s string) {
+	if w.OutputFormat == OutputTSV {
+		tsvEscaper.WriteString(buf, s)
+		return
+	}
+
+	if s == "" || !strings.ContainsAny(s, ",\"\r\n") && s[0] != ' ' && s[0] != '\t' {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteByte('"')
+	buf.WriteString(strings.ReplaceAll(s, `"`, `""`))
+	buf.WriteByte('"')
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
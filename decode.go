@@ -0,0 +1,349 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// InputFormat defines the format of the input passed to Write.
+type InputFormat int
+
+const (
+	// InputJSON decodes every input as a JSON object.
+	InputJSON InputFormat = iota
+	// InputLogfmt decodes every input as a logfmt line.
+	InputLogfmt
+	// InputAuto decodes inputs starting with '{' or '[' as JSON and everything else as
+	// logfmt.
+	InputAuto
+	// InputGELF decodes every input as a GELF message, mapping its fields to the message,
+	// timestamp and level fields and removing the underscore prefix of additional fields.
+	InputGELF
+)
+
+// Decoder decodes the input passed to Write into events. Values must have the types
+// produced by encoding/json with UseNumber: string, json.Number, bool, nil,
+// []interface{} and map[string]interface{}. The events stay owned by the Decoder: they are
+// modified while rendered, but neither retained nor reused by the writer.
+type Decoder interface {
+	Decode(p []byte) ([]map[string]interface{}, error)
+}
+
+// DecoderFunc is an adapter to use an ordinary function as a Decoder.
+type DecoderFunc func(p []byte) ([]map[string]interface{}, error)
+
+// Decode calls f(p).
+func (f DecoderFunc) Decode(p []byte) ([]map[string]interface{}, error) {
+	return f(p)
+}
+
+// JSONDecoder decodes a stream of JSON values, like json.Decoder, e.g. to use a faster JSON
+// library such as jsoniter or go-json. Numbers must be decoded as json.Number and the end
+// of the input reported as io.EOF. Decoders also implementing InputOffset() int64 decode
+// objects into pooled maps.
+type JSONDecoder interface {
+	Decode(v interface{}) error
+}
+
+// offsetDecoder is a JSONDecoder reporting its offset in the input.
+type offsetDecoder interface {
+	JSONDecoder
+	InputOffset() int64
+}
+
+// newStdJSONDecoder creates the default JSONDecoder: encoding/json with UseNumber.
+func newStdJSONDecoder(r io.Reader) JSONDecoder {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return d
+}
+
+// ErrorMode defines what happens to input that cannot be decoded.
+type ErrorMode int
+
+const (
+	// ErrorFail returns a DecodeError from Write.
+	ErrorFail ErrorMode = iota
+	// ErrorDrop silently drops the input.
+	ErrorDrop
+	// ErrorPassThrough writes the input unchanged, prefixed with PassThroughPrefix.
+	ErrorPassThrough
+)
+
+// ArrayInputMode defines how top-level JSON arrays are decoded.
+type ArrayInputMode int
+
+const (
+	// ArrayInputSplit decodes every element of the array as a separate event.
+	ArrayInputSplit ArrayInputMode = iota
+	// ArrayInputIndexed decodes the array as a single event keyed by element indexes.
+	ArrayInputIndexed
+)
+
+// ScalarMode defines how top-level JSON scalars and null are decoded.
+type ScalarMode int
+
+const (
+	// ScalarWrap decodes the value as an event with the single key ScalarKey.
+	ScalarWrap ScalarMode = iota
+	// ScalarPassThrough writes the input unchanged.
+	ScalarPassThrough
+	// ScalarFail handles the value as invalid input according to ErrorMode.
+	ScalarFail
+)
+
+// errScalar is returned when a top-level scalar cannot be decoded into an event.
+var errScalar = errors.New("top-level value is not an object")
+
+// decode decodes the input into events using Decoder, or according to InputFormat if not
+// set. JSON input may contain several objects, logfmt input is always a single event.
+func (w KeyValueWriter) decode(p []byte) ([]map[string]interface{}, error) {
+	if w.Decoder != nil {
+		return w.Decoder.Decode(p)
+	}
+
+	switch w.InputFormat {
+	case InputLogfmt:
+		return decodeLogfmtEvent(p)
+	case InputGELF:
+		return w.decodeGELFEvents(p)
+	case InputAuto:
+		if t := bytes.TrimLeft(p, " \t\r\n"); len(t) == 0 || (t[0] != '{' && t[0] != '[') {
+			return decodeLogfmtEvent(p)
+		}
+	}
+	return w.decodeJSONEvents(p)
+}
+
+// decodeJSONEvents decodes all JSON values of the input, usually separated by newlines.
+// Objects are decoded as events, arrays according to ArrayInput.
+func (w KeyValueWriter) decodeJSONEvents(p []byte) ([]map[string]interface{}, error) {
+	var newDecoder = w.NewJSONDecoder
+	if newDecoder == nil {
+		newDecoder = newStdJSONDecoder
+	}
+
+	var events []map[string]interface{}
+	d := newDecoder(bytes.NewReader(p))
+	od, hasOffset := d.(offsetDecoder)
+	for {
+		// Objects are decoded into pooled maps, released by Write after rendering them.
+		if hasOffset && nextJSONByte(p[od.InputOffset():]) == '{' {
+			var evt = getEvent()
+			if err := d.Decode(&evt); err != nil {
+				putEvents(append(events, evt))
+				return nil, err
+			}
+			events = append(events, evt)
+			continue
+		}
+
+		var v interface{}
+		if err := d.Decode(&v); err == io.EOF && len(events) > 0 {
+			return events, nil
+		} else if err != nil {
+			putEvents(events)
+			return nil, err
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			events = append(events, v)
+		case []interface{}:
+			evts, err := w.arrayEvents(v)
+			if err != nil {
+				putEvents(events)
+				return nil, err
+			}
+			events = append(events, evts...)
+		default:
+			evt, err := w.scalarEvent(v)
+			if err != nil {
+				putEvents(events)
+				return nil, err
+			}
+			events = append(events, evt)
+		}
+	}
+}
+
+// nextJSONByte returns the first byte of p that is not JSON whitespace, or 0 if none.
+func nextJSONByte(p []byte) byte {
+	for _, c := range p {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c
+		}
+	}
+	return 0
+}
+
+// arrayEvents converts a top-level array into events according to ArrayInput.
+func (w KeyValueWriter) arrayEvents(arr []interface{}) ([]map[string]interface{}, error) {
+	if w.ArrayInput == ArrayInputIndexed {
+		var evt = make(map[string]interface{}, len(arr))
+		for i, v := range arr {
+			evt[strconv.Itoa(i)] = v
+		}
+		return []map[string]interface{}{evt}, nil
+	}
+
+	var events = make([]map[string]interface{}, 0, len(arr))
+	for i, v := range arr {
+		evt, ok := v.(map[string]interface{})
+		if !ok {
+			var err error
+			if evt, err = w.scalarEvent(v); err != nil {
+				return nil, fmt.Errorf("array element %d: %w", i, err)
+			}
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// scalarEvent converts a top-level scalar or nested array into an event according to
+// ScalarMode.
+func (w KeyValueWriter) scalarEvent(v interface{}) (map[string]interface{}, error) {
+	if w.ScalarMode != ScalarWrap {
+		return nil, errScalar
+	}
+	return map[string]interface{}{w.ScalarKey: v}, nil
+}
+
+func decodeLogfmtEvent(p []byte) ([]map[string]interface{}, error) {
+	evt, err := decodeLogfmt(p)
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]interface{}{evt}, nil
+}
+
+// decodeLogfmt decodes a logfmt line into an event. Quoted values are strings, unquoted
+// numbers become json.Number, unquoted true and false booleans, and keys without a value
+// are true.
+func decodeLogfmt(p []byte) (map[string]interface{}, error) {
+	var evt = make(map[string]interface{})
+	for i := 0; i < len(p); {
+		if isLogfmtSpace(p[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(p) && p[i] != '=' && !isLogfmtSpace(p[i]) {
+			if p[i] == '"' {
+				return nil, fmt.Errorf("unexpected '\"' in key at offset %d", i)
+			}
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("missing key at offset %d", i)
+		}
+		key := string(p[start:i])
+
+		if i >= len(p) || p[i] != '=' {
+			evt[key] = true
+			continue
+		}
+		i++ // Skip '='
+
+		if i < len(p) && p[i] == '"' {
+			value, n, err := unquoteLogfmt(p[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %q: %s", key, err)
+			}
+			evt[key] = value
+			i += n
+			continue
+		}
+
+		start = i
+		for i < len(p) && !isLogfmtSpace(p[i]) {
+			i++
+		}
+		evt[key] = logfmtScalar(string(p[start:i]))
+	}
+	return evt, nil
+}
+
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// logfmtScalar converts an unquoted logfmt value to a number or boolean if possible.
+func logfmtScalar(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if isJSONNumber(s) {
+		return json.Number(s)
+	}
+	return s
+}
+
+// isJSONNumber reports whether s is a valid JSON number.
+func isJSONNumber(s string) bool {
+	var i int
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if i == len(s) {
+		return false
+	}
+	if s[i] == '0' {
+		i++
+	} else if s[i] >= '1' && s[i] <= '9' {
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+	} else {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if i == len(s) || s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if i == len(s) || s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+	}
+	return i == len(s)
+}
+
+// unquoteLogfmt unquotes the quoted string at the start of p and returns it together with
+// the number of bytes consumed. Escapes follow JSON.
+func unquoteLogfmt(p []byte) (string, int, error) {
+	for i := 1; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '"':
+			var s string
+			if err := json.Unmarshal(p[:i+1], &s); err != nil {
+				return "", 0, err
+			}
+			return s, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted value")
+}
@@ -0,0 +1,77 @@
+package kvwriter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables read by FromEnv.
+const (
+	EnvColor      = "KVWRITER_COLOR"
+	EnvInclude    = "KVWRITER_INCLUDE"
+	EnvExclude    = "KVWRITER_EXCLUDE"
+	EnvOrder      = "KVWRITER_ORDER"
+	EnvTimeLayout = "KVWRITER_TIME_LAYOUT"
+	EnvLevel      = "KVWRITER_LEVEL"
+)
+
+// FromEnv configures the writer from environment variables, so the output can be tweaked
+// per deployment:
+//
+//   - KVWRITER_COLOR: auto, always or never; on, true and 1 are always, off, false and 0
+//     are never
+//   - KVWRITER_INCLUDE and KVWRITER_EXCLUDE: comma-separated keys to display or hide, added
+//     to the configured ones
+//   - KVWRITER_ORDER: comma-separated keys written first
+//   - KVWRITER_TIME_LAYOUT: Go layout of the rendered timestamps
+//   - KVWRITER_LEVEL: minimum level of the written events
+//
+// Unset and empty variables keep the configuration. Invalid values are reported by
+// Validate, so NewKeyValueWriter panics. Options after FromEnv override the environment.
+func FromEnv() Option {
+	return func(w *KeyValueWriter) {
+		if v := os.Getenv(EnvColor); v != "" {
+			switch strings.ToLower(v) {
+			case "auto":
+				w.Colorize = ColorAuto
+			case "always", "on", "true", "1":
+				w.Colorize = ColorAlways
+			case "never", "off", "false", "0":
+				w.Colorize = ColorNever
+			default:
+				w.optionErr = fmt.Errorf("%s: unknown color mode %q", EnvColor, v)
+			}
+		}
+
+		w.KeysInclude = append(w.KeysInclude, envList(EnvInclude)...)
+		w.KeysExclude = append(w.KeysExclude, envList(EnvExclude)...)
+		if keys := envList(EnvOrder); len(keys) > 0 {
+			w.FieldsOrder = keys
+		}
+
+		if v := os.Getenv(EnvTimeLayout); v != "" {
+			w.TimeLayout = v
+		}
+
+		if v := os.Getenv(EnvLevel); v != "" {
+			l, ok := parseLevel(DefaultLevels, v)
+			if !ok {
+				w.optionErr = fmt.Errorf("%s: unknown level %q", EnvLevel, v)
+			}
+			w.MinLevel = l
+		}
+	}
+}
+
+// envList returns the comma-separated list of the environment variable, ignoring empty
+// elements.
+func envList(name string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,126 @@
+package kvwriter
+
+import (
+	"slices"
+	"strconv"
+)
+
+// The keys holding the message and the wrapped errors of error objects, in the order they
+// are looked up.
+var (
+	errorMessageKeys = [...]string{"message", "msg", "error", "err"}
+	errorCauseKeys   = [...]string{"cause", "causes", "wrapped", "errors"}
+)
+
+// collapseErrorChains replaces the error objects in the values of ErrorKeys with their
+// formatted chain, or with one key per error if ExpandErrors is set. Other fields of the
+// outermost error are kept as keys nested in the key of the error. Nested objects and
+// arrays are walked like by KeysInclude, matching ErrorKeys against their flattened keys,
+// e.g. "request.error". parent is the flattened key of obj, empty for the event itself.
+func (w KeyValueWriter) collapseErrorChains(obj map[string]interface{}, parent string) {
+	var keys []string
+	for name, v := range obj {
+		path := w.joinKey(parent, name)
+		if m, ok := v.(map[string]interface{}); ok && matchAny(w.ErrorKeys, path) && isErrorObject(m) {
+			keys = append(keys, name)
+			continue
+		}
+		w.collapseNestedErrorChains(v, path)
+	}
+	slices.Sort(keys)
+
+	sep := w.keySeparator()
+	for _, key := range keys {
+		errObj := obj[key].(map[string]interface{})
+		messages := appendErrorChain(nil, errObj)
+
+		for name, v := range errObj {
+			if !isErrorChainKey(name) {
+				obj[sep.join(key, name)] = v
+			}
+		}
+		if !w.ExpandErrors {
+			obj[key] = w.formatErrorChain(messages)
+			continue
+		}
+		obj[key] = messages[0]
+		for i, msg := range messages[1:] {
+			obj[sep.join(key, strconv.Itoa(i+1))] = msg
+		}
+	}
+}
+
+func (w KeyValueWriter) collapseNestedErrorChains(v interface{}, path string) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		w.collapseErrorChains(vv, path)
+	case []interface{}:
+		for i, elem := range vv {
+			w.collapseNestedErrorChains(elem, w.joinKey(path, strconv.Itoa(i)))
+		}
+	}
+}
+
+// formatErrorChain formats the messages of an error chain with FormatError, or as the
+// outermost message followed by the number of wrapped errors.
+func (w KeyValueWriter) formatErrorChain(messages []string) string {
+	if w.FormatError != nil {
+		return w.FormatError(messages)
+	}
+	if len(messages) == 1 {
+		return messages[0]
+	}
+	return messages[0] + " (" + strconv.Itoa(len(messages)-1) + " wrapped)"
+}
+
+// isErrorObject reports whether obj has a message and wrapped errors.
+func isErrorObject(obj map[string]interface{}) bool {
+	if _, ok := errorMessage(obj); !ok {
+		return false
+	}
+	for _, k := range errorCauseKeys {
+		switch obj[k].(type) {
+		case string, map[string]interface{}, []interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+// isErrorChainKey reports whether name holds the message or wrapped errors of an error
+// object.
+func isErrorChainKey(name string) bool {
+	return slices.Contains(errorMessageKeys[:], name) || slices.Contains(errorCauseKeys[:], name)
+}
+
+func errorMessage(obj map[string]interface{}) (string, bool) {
+	for _, k := range errorMessageKeys {
+		if s, ok := obj[k].(string); ok {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// appendErrorChain appends the messages of the error v and of its wrapped errors, depth
+// first. Errors are objects, arrays of errors or plain messages.
+func appendErrorChain(messages []string, v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		messages = append(messages, v)
+	case []interface{}:
+		for _, elem := range v {
+			messages = appendErrorChain(messages, elem)
+		}
+	case map[string]interface{}:
+		if msg, ok := errorMessage(v); ok {
+			messages = append(messages, msg)
+		}
+		for _, k := range errorCauseKeys {
+			if cause, ok := v[k]; ok {
+				messages = appendErrorChain(messages, cause)
+			}
+		}
+	}
+	return messages
+}
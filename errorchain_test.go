@@ -0,0 +1,57 @@
+package kvwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestErrorKeys(t *testing.T) {
+	const input = `{"error":{"message":"query failed","code":5,"cause":{"msg":"timeout"}},` +
+		`"request":{"id":1,"error":{"err":"denied","wrapped":["no token"]}},` +
+		`"batch":[{"error":{"message":"skipped","causes":"empty"}}],"result":{"message":"ok"}}`
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name:    "top level",
+			options: []Option{WithErrorKeys("error")},
+			want: `batch.0.error.causes="empty" batch.0.error.message="skipped" error="query failed (1 wrapped)" error.code="5" ` +
+				`request.error.err="denied" request.error.wrapped.0="no token" request.id="1" result.message="ok"` + "\n",
+		},
+		{
+			name:    "flattened key",
+			options: []Option{WithErrorKeys("request.error")},
+			want: `batch.0.error.causes="empty" batch.0.error.message="skipped" error.cause.msg="timeout" error.code="5" error.message="query failed" ` +
+				`request.error="denied (1 wrapped)" request.id="1" result.message="ok"` + "\n",
+		},
+		{
+			name:    "glob",
+			options: []Option{WithErrorKeys("*error", "result")},
+			want: `batch.0.error="skipped (1 wrapped)" error="query failed (1 wrapped)" error.code="5" ` +
+				`request.error="denied (1 wrapped)" request.id="1" result.message="ok"` + "\n",
+		},
+		{
+			name:    "expanded",
+			options: []Option{WithErrorKeys("*error"), WithExpandedErrors(true), WithKeysInclude("request.*")},
+			want:    `request.error="denied" request.error.1="no token" request.id="1"` + "\n",
+		},
+		{
+			name:    "underscore",
+			options: []Option{WithErrorKeys("request_error"), WithFlattenStyle(FlattenUnderscore), WithKeysInclude("request_*")},
+			want:    `request_error="denied (1 wrapped)" request_id="1"` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := NewKeyValueWriter(append(tt.options, WithOutput(&out))...)
+		if _, err := w.Write([]byte(input)); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s: got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package kvwriter
+
+import "errors"
+
+// Errors returned by Write and WriteEvent, matched with errors.Is. They tell input that
+// cannot be written, which retrying does not fix, from a broken output.
+var (
+	// ErrDecode matches the errors of input that cannot be decoded, a *DecodeError.
+	ErrDecode = errors.New("kvwriter: cannot decode event")
+
+	// ErrFormat matches the errors of formatting a decoded event, e.g. returned by
+	// FormatExtra.
+	ErrFormat = errors.New("kvwriter: cannot format event")
+
+	// ErrFlatten matches the errors of flattening a decoded event or transforming its keys,
+	// e.g. colliding keys with CollisionError.
+	ErrFlatten = errors.New("kvwriter: cannot flatten event")
+
+	// ErrSink matches the errors of writing to Out, a *SinkError.
+	ErrSink = errors.New("kvwriter: cannot write output")
+)
+
+// DecodeError is returned for input that cannot be decoded.
+type DecodeError struct {
+	// Input is a copy of the input that could not be decoded.
+	Input []byte
+
+	// Err is the error of the decoder, or the one returned by OnDecodeError.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return ErrDecode.Error() + ": " + e.Err.Error()
+}
+
+// Is reports whether target is ErrDecode.
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// SinkError is returned for output that cannot be written to Out.
+type SinkError struct {
+	// Output is a copy of the lines that could not be written.
+	Output []byte
+
+	// Err is the error of Out.
+	Err error
+}
+
+func (e *SinkError) Error() string {
+	return ErrSink.Error() + ": " + e.Err.Error()
+}
+
+// Is reports whether target is ErrSink.
+func (e *SinkError) Is(target error) bool {
+	return target == ErrSink
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,218 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// WriteEvent formats the event and appends it to w.Out, skipping the JSON decoding done by
+// Write. Values are normalized to their JSON representation: numbers become json.Number,
+// time.Time an RFC3339 string, errors and time.Duration their string, and other values
+// are encoded to JSON and decoded back. The event and the maps and slices nested in it
+// may be modified.
+func (w KeyValueWriter) WriteEvent(evt map[string]interface{}) error {
+	var buf = getBuffer(0)
+	defer putBuffer(buf, w.MaxBufferSize)
+
+	normalizeMap(evt)
+
+	ok, err := w.renderEvent(evt, buf)
+	if err != nil {
+		return err
+	}
+	w.countEvent(ok)
+	if buf.Len() == 0 {
+		return nil
+	}
+	return w.writeOut(buf)
+}
+
+// FormatEvent formats the event like WriteEvent but returns the line instead of writing it
+// to w.Out. It returns nil if the event was dropped.
+func (w KeyValueWriter) FormatEvent(evt map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	normalizeMap(evt)
+
+	ok, err := w.renderEvent(evt, &buf)
+	if err != nil {
+		return nil, err
+	}
+	w.countEvent(ok)
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeFunc decodes p like Write and calls fn with every event instead of writing it,
+// for sinks that handle each event on its own, e.g. as a syslog message. Input that cannot
+// be decoded is handled by OnDecodeError and ErrorMode like in Write, and its output, e.g.
+// the invalid line with ErrorPassThrough, is passed to fn as raw with a nil event. The
+// events are reused after fn returns. DecodeFunc returns the number of bytes of p handled
+// and stops at the first error of fn or DecodeError.
+func (w KeyValueWriter) DecodeFunc(p []byte, fn func(evt map[string]interface{}, raw []byte) error) (int, error) {
+	events, err := w.decode(p)
+	if err == nil {
+		defer w.releaseEvents(events)
+		for _, evt := range events {
+			if err := fn(evt, nil); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if w.Decoder != nil || bytes.IndexByte(bytes.TrimSpace(p), '\n') < 0 {
+		if err := w.decodeFuncError(p, err, fn); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	var n int
+	for rest := p; len(rest) > 0; {
+		line, next, _ := bytes.Cut(rest, []byte{'\n'})
+		consumed := len(rest) - len(next)
+		rest = next
+		if len(bytes.TrimSpace(line)) > 0 {
+			if _, err := w.DecodeFunc(line, fn); err != nil {
+				return n, err
+			}
+		}
+		n += consumed
+	}
+	return n, nil
+}
+
+// decodeFuncError passes the output for input that cannot be decoded to fn, or returns the
+// DecodeError.
+func (w KeyValueWriter) decodeFuncError(p []byte, err error, fn func(map[string]interface{}, []byte) error) error {
+	var buf = getBuffer(len(p))
+	defer putBuffer(buf, w.MaxBufferSize)
+
+	if err := w.appendDecodeError(p, err, buf); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return fn(nil, buf.Bytes())
+}
+
+// MissingValue is written for the last key of an odd number of keyvals.
+const MissingValue = "(MISSING)"
+
+// WriteKeyvals writes alternating keys and values as a single event, like WriteEvent.
+// Keys that are not strings are formatted with fmt.Sprint.
+func (w KeyValueWriter) WriteKeyvals(keyvals ...interface{}) error {
+	return w.WriteEvent(KeyvalsEvent(keyvals...))
+}
+
+// KeyvalsEvent converts alternating keys and values to an event. If the number of keyvals
+// is odd, the value of the last key is MissingValue.
+func KeyvalsEvent(keyvals ...interface{}) map[string]interface{} {
+	var evt = make(map[string]interface{}, (len(keyvals)+1)/2)
+	AppendKeyvals(evt, keyvals...)
+	return evt
+}
+
+// AppendKeyvals adds alternating keys and values to evt, replacing existing keys.
+func AppendKeyvals(evt map[string]interface{}, keyvals ...interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		var key string
+		switch k := keyvals[i].(type) {
+		case string:
+			key = k
+		default:
+			key = fmt.Sprint(k)
+		}
+
+		if i+1 < len(keyvals) {
+			evt[key] = keyvals[i+1]
+		} else {
+			evt[key] = MissingValue
+		}
+	}
+}
+
+func normalizeMap(m map[string]interface{}) {
+	for k, v := range m {
+		m[k] = normalizeValue(v)
+	}
+}
+
+// normalizeValue converts v to the types produced by decoding JSON with UseNumber.
+func normalizeValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case nil, string, bool, json.Number, json.RawMessage:
+		return v
+	case map[string]interface{}:
+		normalizeMap(vv)
+		return vv
+	case []interface{}:
+		for i, elem := range vv {
+			vv[i] = normalizeValue(elem)
+		}
+		return vv
+	case int:
+		return json.Number(strconv.FormatInt(int64(vv), 10))
+	case int8:
+		return json.Number(strconv.FormatInt(int64(vv), 10))
+	case int16:
+		return json.Number(strconv.FormatInt(int64(vv), 10))
+	case int32:
+		return json.Number(strconv.FormatInt(int64(vv), 10))
+	case int64:
+		return json.Number(strconv.FormatInt(vv, 10))
+	case uint:
+		return json.Number(strconv.FormatUint(uint64(vv), 10))
+	case uint8:
+		return json.Number(strconv.FormatUint(uint64(vv), 10))
+	case uint16:
+		return json.Number(strconv.FormatUint(uint64(vv), 10))
+	case uint32:
+		return json.Number(strconv.FormatUint(uint64(vv), 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(vv, 10))
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(vv), 'g', -1, 32))
+	case float64:
+		return json.Number(strconv.FormatFloat(vv, 'g', -1, 64))
+	case time.Time:
+		return vv.Format(time.RFC3339Nano)
+	case time.Duration:
+		return vv.String()
+	case json.Marshaler:
+		return decodeJSON(vv)
+	case error:
+		return vv.Error()
+	case fmt.Stringer:
+		if rv := reflect.ValueOf(vv); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		return vv.String()
+	case []byte:
+		return string(vv)
+	default:
+		return decodeJSON(vv)
+	}
+}
+
+// decodeJSON encodes v to JSON and decodes it back using json.Number for numbers.
+func decodeJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("[error: %v]", err)
+	}
+	var out interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&out); err != nil {
+		return fmt.Sprintf("[error: %v]", err)
+	}
+	return out
+}
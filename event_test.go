@@ -0,0 +1,27 @@
+package kvwriter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeFunc(t *testing.T) {
+	const in = "{\"level\":\"bad\",\"msg\":\"a\"}\nnot json\n[{\"msg\":\"b\"}]\n"
+	w := NewKeyValueWriter(WithErrorMode(ErrorPassThrough), WithLevelTable(map[string]Level{"bad": LevelError}))
+
+	var got []string
+	n, err := w.DecodeFunc([]byte(in), func(evt map[string]interface{}, raw []byte) error {
+		if evt == nil {
+			got = append(got, "raw "+strings.TrimSpace(string(raw)))
+		} else {
+			got = append(got, evt["msg"].(string)+" "+w.EventLevel(evt).String())
+		}
+		return nil
+	})
+	if err != nil || n != len(in) {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if want := "a error|raw not json|b "; strings.Join(got, "|") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, "|"), want)
+	}
+}
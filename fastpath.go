@@ -0,0 +1,322 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+// errFastPath reports input the fast path cannot write, e.g. invalid JSON or a top-level
+// array, which is then written by the regular path.
+var errFastPath = errors.New("input not supported by the fast path")
+
+// fastPathEnabled reports whether FastPath is enabled and the configuration does not
+// need the whole event before writing its pairs.
+func (w KeyValueWriter) fastPathEnabled() bool {
+	return w.FastPath &&
+		w.Decoder == nil && (w.InputFormat == InputJSON || w.InputFormat == InputAuto) && w.OutputFormat == OutputKeyValue &&
+		len(w.FieldsOrder) == 0 && w.KeySort == nil && !w.AlignValues && !w.Multiline &&
+		!w.Nested && w.MaxDepth == 0 && w.CollisionMode == CollisionLastWins && w.ArrayMode == ArrayIndexKeys &&
+		w.KeyCase == KeyCaseNone && len(w.KeysRename) == 0 && len(w.KeyTrimPrefixes) == 0 &&
+		w.MaxLineLength == 0 && w.FilterEvent == nil && len(w.Pipeline) == 0 &&
+		w.MinLevel == LevelUnset && w.Sampler == nil && w.RateLimit == 0 && !w.Dedup &&
+		len(w.RedactValues) == 0 && len(w.RedactKeys) == 0 && len(w.HashKeys) == 0 &&
+		!w.OmitEmpty && w.NullMode != NullOmit && w.Template == "" && len(w.StackTraceKeys) == 0 && len(w.ErrorKeys) == 0 && w.JSONStrings == JSONStringsKeep && w.FormatExtra == nil && w.Hooks.BeforeWrite == nil
+}
+
+// writeFast writes the JSON objects of p to buf while scanning them, without decoding
+// them into maps. Pairs are written in input order. It returns the number of written
+// events, or errFastPath with buf unchanged if p is not a sequence of JSON objects or an
+// object has duplicate flattened keys, which the regular path resolves by CollisionMode.
+func (w KeyValueWriter) writeFast(p []byte, buf *bytes.Buffer) (int, error) {
+	w.checkFilters()
+	var start = buf.Len()
+	s := fastScanner{w: w, buf: buf, p: p, sep: w.keySeparator(), keys: getKeys()}
+	defer putKeys(s.keys)
+	s.fk, s.fv = w.formatters()
+	s.pd = w.pairsDelimiter()
+
+	var events int
+	for {
+		s.skipSpace()
+		if s.i == len(p) && events > 0 {
+			return events, nil
+		}
+		if s.i == len(p) || p[s.i] != '{' {
+			buf.Truncate(start)
+			return 0, errFastPath
+		}
+
+		s.pairs = 0
+		*s.keys = (*s.keys)[:0]
+		clear(s.seen)
+		if err := s.object(""); err != nil {
+			buf.Truncate(start)
+			return 0, errFastPath
+		}
+		buf.WriteByte('\n')
+		events++
+	}
+}
+
+// fastScanner scans JSON input, writing the flattened pairs of an object as they are
+// scanned.
+type fastScanner struct {
+	w   KeyValueWriter
+	buf *bytes.Buffer
+	p   []byte
+	i   int
+
+	sep    keySeparator
+	fk, fv Formatter
+	pd     string
+	pairs  int
+
+	// keys are the written keys of the current object, indexed by seen once there are
+	// more than maxLinearKeys.
+	keys *[]string
+	seen map[string]struct{}
+}
+
+// maxLinearKeys is the number of keys up to which duplicates are searched linearly.
+const maxLinearKeys = 32
+
+// duplicate records the written key and reports whether it was written before in the
+// current object.
+func (s *fastScanner) duplicate(key string) bool {
+	if s.seen == nil && len(*s.keys) < maxLinearKeys {
+		for _, k := range *s.keys {
+			if k == key {
+				return true
+			}
+		}
+		*s.keys = append(*s.keys, key)
+		return false
+	}
+
+	if s.seen == nil {
+		s.seen = make(map[string]struct{}, 2*maxLinearKeys)
+	}
+	if len(s.seen) == 0 {
+		for _, k := range *s.keys {
+			s.seen[k] = struct{}{}
+		}
+	}
+	if _, ok := s.seen[key]; ok {
+		return true
+	}
+	s.seen[key] = struct{}{}
+	return false
+}
+
+func (s *fastScanner) skipSpace() {
+	for s.i < len(s.p) {
+		switch s.p[s.i] {
+		case ' ', '\t', '\r', '\n':
+			s.i++
+		default:
+			return
+		}
+	}
+}
+
+// object scans an object whose keys are nested in prefix.
+func (s *fastScanner) object(prefix string) error {
+	s.i++ // {
+	s.skipSpace()
+	if s.i < len(s.p) && s.p[s.i] == '}' {
+		s.i++
+		return nil
+	}
+
+	for {
+		s.skipSpace()
+		if s.i == len(s.p) || s.p[s.i] != '"' {
+			return errFastPath
+		}
+		name, err := s.string()
+		if err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.i == len(s.p) || s.p[s.i] != ':' {
+			return errFastPath
+		}
+		s.i++
+
+		var key = name
+		if prefix != "" {
+			key = s.sep.join(prefix, name)
+		}
+		if err := s.value(key); err != nil {
+			return err
+		}
+
+		s.skipSpace()
+		if s.i == len(s.p) {
+			return errFastPath
+		}
+		switch s.p[s.i] {
+		case ',':
+			s.i++
+		case '}':
+			s.i++
+			return nil
+		default:
+			return errFastPath
+		}
+	}
+}
+
+// array scans an array whose elements are keyed by their index nested in prefix.
+func (s *fastScanner) array(prefix string) error {
+	s.i++ // [
+	s.skipSpace()
+	if s.i < len(s.p) && s.p[s.i] == ']' {
+		s.i++
+		return nil
+	}
+
+	for n := 0; ; n++ {
+		if err := s.value(s.sep.join(prefix, strconv.Itoa(n))); err != nil {
+			return err
+		}
+
+		s.skipSpace()
+		if s.i == len(s.p) {
+			return errFastPath
+		}
+		switch s.p[s.i] {
+		case ',':
+			s.i++
+		case ']':
+			s.i++
+			return nil
+		default:
+			return errFastPath
+		}
+	}
+}
+
+// value scans the value of key and writes it, unless it is an object or an array.
+func (s *fastScanner) value(key string) error {
+	s.skipSpace()
+	if s.i == len(s.p) {
+		return errFastPath
+	}
+
+	var v interface{}
+	switch c := s.p[s.i]; {
+	case c == '{':
+		return s.object(key)
+	case c == '[':
+		return s.array(key)
+	case c == '"':
+		str, err := s.string()
+		if err != nil {
+			return err
+		}
+		v = str
+	case c == 't' && bytes.HasPrefix(s.p[s.i:], []byte("true")):
+		s.i += 4
+		v = true
+	case c == 'f' && bytes.HasPrefix(s.p[s.i:], []byte("false")):
+		s.i += 5
+		v = false
+	case c == 'n' && bytes.HasPrefix(s.p[s.i:], []byte("null")):
+		s.i += 4
+	case c == '-' || c >= '0' && c <= '9':
+		n, err := s.number()
+		if err != nil {
+			return err
+		}
+		v = n
+	default:
+		return errFastPath
+	}
+
+	if !s.w.keepKey(key) {
+		return nil
+	}
+	if s.duplicate(key) {
+		return errFastPath
+	}
+	if s.pairs > 0 {
+		s.buf.WriteString(s.pd)
+	}
+	s.pairs++
+	s.w.writePair(s.buf, key, v, s.fk, s.fv)
+	return nil
+}
+
+// string scans a string. Strings with escapes or invalid UTF-8 are decoded by
+// encoding/json.
+func (s *fastScanner) string() (string, error) {
+	start := s.i
+	s.i++ // "
+	var escaped bool
+	for s.i < len(s.p) {
+		switch c := s.p[s.i]; {
+		case c == '"':
+			s.i++
+			raw := s.p[start+1 : s.i-1]
+			if !escaped && utf8.Valid(raw) {
+				return string(raw), nil
+			}
+			var str string
+			if err := json.Unmarshal(s.p[start:s.i], &str); err != nil {
+				return "", errFastPath
+			}
+			return str, nil
+		case c == '\\':
+			escaped = true
+			s.i += 2
+		case c < ' ':
+			return "", errFastPath
+		default:
+			s.i++
+		}
+	}
+	return "", errFastPath
+}
+
+// number scans a number.
+func (s *fastScanner) number() (json.Number, error) {
+	start := s.i
+	if s.p[s.i] == '-' {
+		s.i++
+	}
+	switch {
+	case s.i < len(s.p) && s.p[s.i] == '0':
+		s.i++
+	case s.digits() == 0:
+		return "", errFastPath
+	}
+	if s.i < len(s.p) && s.p[s.i] == '.' {
+		s.i++
+		if s.digits() == 0 {
+			return "", errFastPath
+		}
+	}
+	if s.i < len(s.p) && (s.p[s.i] == 'e' || s.p[s.i] == 'E') {
+		s.i++
+		if s.i < len(s.p) && (s.p[s.i] == '+' || s.p[s.i] == '-') {
+			s.i++
+		}
+		if s.digits() == 0 {
+			return "", errFastPath
+		}
+	}
+	return json.Number(s.p[start:s.i]), nil
+}
+
+// digits scans decimal digits and returns their count.
+func (s *fastScanner) digits() int {
+	start := s.i
+	for s.i < len(s.p) && s.p[s.i] >= '0' && s.p[s.i] <= '9' {
+		s.i++
+	}
+	return s.i - start
+}
@@ -0,0 +1,61 @@
+package kvwriter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// fastPathInputs have their flattened keys in sorted order, so both paths write the
+// pairs in the same order.
+var fastPathInputs = []string{
+	`{"a":1,"b":"two","c":true,"d":null}`,
+	`{"a":{"b":{"c":-1.5e3}},"a.d":[1,"x",[false]],"e":{}}`,
+	`{"msg":"esc\"aped\n\u00e9 \ud83d\ude00","n":0}`,
+	`{"a":1}` + "\n" + `{"b":2}` + "\n",
+	`{"a":1,"a":2}`,
+	`{"x.y":1,"x":{"y":2}}`,
+	`{"a":[1,2],"a.0":3}`,
+	`[{"a":1}]`,
+	`{"a":1`,
+}
+
+func TestFastPathMatchesRegularPath(t *testing.T) {
+	for _, in := range fastPathInputs {
+		var fast, regular bytes.Buffer
+		fw := NewKeyValueWriter(WithOutput(&fast), WithFastPath(true), WithErrorMode(ErrorPassThrough))
+		rw := NewKeyValueWriter(WithOutput(&regular), WithErrorMode(ErrorPassThrough))
+
+		_, ferr := fw.Write([]byte(in))
+		_, rerr := rw.Write([]byte(in))
+		if (ferr != nil) != (rerr != nil) {
+			t.Errorf("%s: fast path error %v, regular path error %v", in, ferr, rerr)
+		}
+		if fast.String() != regular.String() {
+			t.Errorf("%s:\nfast path    %q\nregular path %q", in, fast.String(), regular.String())
+		}
+	}
+}
+
+var benchmarkEvent = []byte(`{"time":"2024-05-01T12:00:00Z","level":"info","message":"request served",` +
+	`"http":{"method":"GET","path":"/api/v1/users","status":200},"duration_ms":12.5,"user":{"id":42,"admin":false}}`)
+
+func BenchmarkWriteFastPath(b *testing.B) {
+	w := NewKeyValueWriter(WithOutput(io.Discard), WithFastPath(true))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(benchmarkEvent); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteRegularPath(b *testing.B) {
+	w := NewKeyValueWriter(WithOutput(io.Discard))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(benchmarkEvent); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
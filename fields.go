@@ -0,0 +1,221 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldFormatter returns the formatter for values of key. Formatters registered in
+// FormatFieldValue take precedence, then well-known fields have dedicated formatters and
+// all other keys use fv, unless humanized as durations, byte sizes or epoch
+// timestamps, or transformed by UnitFormatters. Epochs detected by DetectEpochs are only
+// rendered for keys without any other formatter. A nil fv denotes the default formatter
+// and is returned for keys without a dedicated formatter.
+func (w KeyValueWriter) fieldFormatter(key string, fv Formatter) Formatter {
+	if f, ok := w.FormatFieldValue[key]; ok && f != nil {
+		return f
+	}
+
+	var base = fv
+	if base == nil {
+		base = defaultFormatValue
+	}
+
+	switch {
+	case key == "":
+		return fv
+	case key == w.TimestampFieldName:
+		if w.FormatTimestamp != nil {
+			return w.FormatTimestamp
+		}
+		if w.TimeLayout != "" {
+			return w.defaultFormatTimestamp
+		}
+	case key == w.LevelFieldName:
+		if w.FormatLevel != nil {
+			return w.FormatLevel
+		}
+		if w.AbbreviateLevels || w.LevelWidth > 0 || w.LevelIcons != LevelIconsOff {
+			return w.defaultFormatLevel
+		}
+	case key == w.MessageFieldName:
+		if w.FormatMessage != nil {
+			return w.FormatMessage
+		}
+	case key == w.CallerFieldName:
+		if w.FormatCaller != nil {
+			return w.FormatCaller
+		}
+		if w.CallerPathSegments > 0 {
+			return w.defaultFormatCaller
+		}
+	}
+
+	if len(w.DurationKeys) > 0 {
+		if unit, ok := w.lookupDurationUnit(key); ok {
+			return numericFormatter(DurationFormatter(unit, w.DurationPrecision), base)
+		}
+	}
+	if len(w.ByteSizeKeys) > 0 && matchAny(w.ByteSizeKeys, key) {
+		return numericFormatter(ByteSizeFormatter(w.ByteUnits), base)
+	}
+	if len(w.EpochKeys) > 0 && matchAny(w.EpochKeys, key) {
+		return w.epochFormatter(base, false)
+	}
+	if len(w.UnitFormatters) > 0 {
+		if f, ok := w.unitFormatter(key); ok {
+			return func(i interface{}) string {
+				return base(f(i))
+			}
+		}
+	}
+	if w.DetectEpochs {
+		return w.epochFormatter(base, true)
+	}
+	return fv
+}
+
+// quoteField reports whether values of key are quoted, with QuoteAuto only if necessary.
+func (w KeyValueWriter) quoteField(key string) bool {
+	if w.UnquotedMessage && key != "" && key == w.MessageFieldName {
+		return false
+	}
+	return w.QuoteValues || w.QuoteMode == QuoteAuto
+}
+
+// defaultFormatTimestamp renders RFC3339 and Unix epoch timestamps using TimeLayout.
+// Values that cannot be parsed are returned unchanged.
+func (w KeyValueWriter) defaultFormatTimestamp(i interface{}) string {
+	t, ok := parseTimestamp(i)
+	if !ok {
+		return defaultFormatValue(i)
+	}
+	return t.Local().Format(w.TimeLayout)
+}
+
+// parseTimestamp parses RFC3339 strings and Unix epoch numbers. The unit of an epoch
+// (seconds, milliseconds, microseconds or nanoseconds) is detected by its magnitude.
+func parseTimestamp(i interface{}) (time.Time, bool) {
+	var s string
+	switch v := i.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	default:
+		return time.Time{}, false
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return epochTime(n), true
+	}
+
+	if strings.ContainsAny(s, ".eE") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return epochFloatTime(f), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// epochFormatter returns a formatter rendering Unix epoch numbers as timestamps in
+// TimeLayout, or RFC3339 in UTC if not set. If detect is set, only integers within
+// [minDetectedEpochYear, maxDetectedEpochYear) are rendered. Other values are rendered by
+// fv.
+func (w KeyValueWriter) epochFormatter(fv Formatter, detect bool) Formatter {
+	return func(i interface{}) string {
+		n, ok := i.(json.Number)
+		if !ok {
+			return fv(i)
+		}
+
+		var t time.Time
+		if detect {
+			v, err := strconv.ParseInt(n.String(), 10, 64)
+			if err != nil {
+				return fv(i)
+			}
+			if t = epochTime(v); t.Year() < minDetectedEpochYear || t.Year() >= maxDetectedEpochYear {
+				return fv(i)
+			}
+		} else if t, ok = parseTimestamp(n); !ok {
+			return fv(i)
+		}
+
+		if w.TimeLayout == "" {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+		return t.Local().Format(w.TimeLayout)
+	}
+}
+
+// The range of years of integers detected as epoch timestamps by DetectEpochs.
+const (
+	minDetectedEpochYear = 2000
+	maxDetectedEpochYear = 2100
+)
+
+// epochTime converts an integer Unix timestamp of unknown unit into time.
+func epochTime(n int64) time.Time {
+	var abs = n
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < 1e11:
+		return time.Unix(n, 0)
+	case abs < 1e14:
+		return time.Unix(0, n*int64(time.Millisecond))
+	case abs < 1e17:
+		return time.Unix(0, n*int64(time.Microsecond))
+	default:
+		return time.Unix(0, n)
+	}
+}
+
+// epochFloatTime converts a Unix timestamp with a fraction of unknown unit into time,
+// detecting the unit like epochTime, e.g. 1.7e12 is in milliseconds.
+func epochFloatTime(f float64) time.Time {
+	var unit = time.Second
+	switch abs := math.Abs(f); {
+	case abs < 1e11:
+	case abs < 1e14:
+		unit = time.Millisecond
+	case abs < 1e17:
+		unit = time.Microsecond
+	default:
+		unit = time.Nanosecond
+	}
+	sec, frac := math.Modf(f * float64(unit) / float64(time.Second))
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+// TrimCallerPath keeps only the last n segments of the caller path, e.g. with n = 2
+// "/src/app/internal/http/server.go:123" becomes "http/server.go:123". If n is not
+// positive the caller is returned unchanged.
+func TrimCallerPath(caller string, n int) string {
+	if n <= 0 {
+		return caller
+	}
+	var i = len(caller)
+	for ; n > 0; n-- {
+		i = strings.LastIndexAny(caller[:i], `/\`)
+		if i < 0 {
+			return caller
+		}
+	}
+	return caller[i+1:]
+}
+
+// defaultFormatCaller trims the caller path to CallerPathSegments.
+func (w KeyValueWriter) defaultFormatCaller(i interface{}) string {
+	return TrimCallerPath(defaultFormatValue(i), w.CallerPathSegments)
+}
@@ -0,0 +1,45 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimestampUnits(t *testing.T) {
+	want := time.Date(2023, 11, 14, 22, 13, 20, 500000000, time.UTC)
+	for _, in := range []json.Number{
+		"1700000000.5", "1.7000000005e9",
+		"1700000000500", "1700000000500.0", "1.7000000005e12",
+		"1700000000500000", "1.7000000005e15",
+		"1700000000500000000", "1.7000000005e18",
+	} {
+		got, ok := parseTimestamp(in)
+		if !ok || got.Sub(want).Abs() > time.Microsecond {
+			t.Errorf("%s: got %v, want %v", in, got.UTC(), want)
+		}
+	}
+}
+
+func TestDetectEpochsUnitFormatters(t *testing.T) {
+	var out bytes.Buffer
+	w := NewKeyValueWriter(WithOutput(&out), WithDetectEpochs(true), WithDefaultUnitFormatters())
+	if _, err := w.Write([]byte(`{"lat_ms":1500,"at":1700000000}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "at=\"2023-11-14T22:13:20Z\" lat_ms=\"1.5s\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDetectEpochsPreserveTypes(t *testing.T) {
+	var out bytes.Buffer
+	w := NewKeyValueWriter(WithOutput(&out), WithDetectEpochs(true), WithPreserveTypes(true))
+	if _, err := w.Write([]byte(`{"n":5,"b":true,"at":1700000000}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "at=\"2023-11-14T22:13:20Z\" b=true n=5\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
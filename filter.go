@@ -0,0 +1,164 @@
+package kvwriter
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// keepKey reports whether key should be written according to the include and exclude
+// filters.
+func (w KeyValueWriter) keepKey(key string) bool {
+	f := w.filter
+	if f == nil {
+		return w.keepKeySlow(key)
+	}
+	if len(w.KeysInclude) > 0 || len(w.KeysIncludeRegex) > 0 {
+		if !f.include.match(key) && !matchAnyRegex(w.KeysIncludeRegex, key) {
+			return false
+		}
+	}
+	return !f.exclude.match(key) && !matchAnyRegex(w.KeysExcludeRegex, key)
+}
+
+// keepKeySlow is keepKey for writers whose filters are not compiled, e.g. created
+// without NewKeyValueWriter.
+func (w KeyValueWriter) keepKeySlow(key string) bool {
+	if len(w.KeysInclude) > 0 || len(w.KeysIncludeRegex) > 0 {
+		if !matchAny(w.KeysInclude, key) && !matchAnyRegex(w.KeysIncludeRegex, key) {
+			return false
+		}
+	}
+	return !matchAny(w.KeysExclude, key) && !matchAnyRegex(w.KeysExcludeRegex, key)
+}
+
+// keyFilter holds KeysInclude, KeysExclude and FieldsOrder compiled by NewKeyValueWriter
+// and With. Keys without wildcards are looked up in a set, so only glob patterns are
+// matched one by one. The filter keeps copies of the fields it was compiled from and is
+// dropped by checkFilters once they are modified.
+type keyFilter struct {
+	includeFrom, excludeFrom, orderFrom []string
+	include, exclude                    keySet
+	priority                            map[string]int
+}
+
+// keySet is a set of glob patterns.
+type keySet struct {
+	keys  map[string]struct{}
+	globs []string
+}
+
+// compileFilters compiles the include and exclude filters and the fields order of the
+// writer.
+func (w *KeyValueWriter) compileFilters() {
+	if len(w.KeysInclude) == 0 && len(w.KeysExclude) == 0 && len(w.FieldsOrder) == 0 {
+		w.filter = nil
+		return
+	}
+	w.filter = &keyFilter{
+		includeFrom: slices.Clone(w.KeysInclude),
+		excludeFrom: slices.Clone(w.KeysExclude),
+		orderFrom:   slices.Clone(w.FieldsOrder),
+		include:     newKeySet(w.KeysInclude),
+		exclude:     newKeySet(w.KeysExclude),
+		priority:    fieldsPriority(w.FieldsOrder),
+	}
+}
+
+// checkFilters drops the compiled filter if KeysInclude, KeysExclude or FieldsOrder were
+// modified since it was compiled, so that the fields are used directly. It is called once
+// per event before keys are filtered or sorted.
+func (w *KeyValueWriter) checkFilters() {
+	if f := w.filter; f != nil && !(slices.Equal(f.includeFrom, w.KeysInclude) &&
+		slices.Equal(f.excludeFrom, w.KeysExclude) && slices.Equal(f.orderFrom, w.FieldsOrder)) {
+		w.filter = nil
+	}
+}
+
+func newKeySet(patterns []string) keySet {
+	var s keySet
+	for _, p := range patterns {
+		if strings.ContainsAny(p, "*?") {
+			s.globs = append(s.globs, p)
+			continue
+		}
+		if s.keys == nil {
+			s.keys = make(map[string]struct{}, len(patterns))
+		}
+		s.keys[p] = struct{}{}
+	}
+	return s
+}
+
+// match reports whether key matches any pattern of the set.
+func (s keySet) match(key string) bool {
+	if _, ok := s.keys[key]; ok {
+		return true
+	}
+	return matchAny(s.globs, key)
+}
+
+// matchAny reports whether key matches any of the glob patterns.
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyRegex reports whether key matches any of the regular expressions.
+func matchAnyRegex(res []*regexp.Regexp, key string) bool {
+	for _, re := range res {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether s matches the glob pattern. '*' matches any sequence of
+// characters, including the flatten separator, and '?' matches a single character.
+// Every other character matches itself.
+func matchGlob(pattern, s string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == s
+	}
+
+	// Position to resume from when a '*' has to consume one more character.
+	var starP, starS = -1, 0
+	var p, i int
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starS = p, i
+				p++
+				continue
+			case '?':
+				_, n := utf8.DecodeRuneInString(s[i:])
+				p++
+				i += n
+				continue
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		_, n := utf8.DecodeRuneInString(s[starS:])
+		starS += n
+		p, i = starP+1, starS
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,32 @@
+package kvwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFiltersModifiedInPlace(t *testing.T) {
+	var out bytes.Buffer
+	write := func(w KeyValueWriter, want string) {
+		t.Helper()
+		out.Reset()
+		if _, err := w.Write([]byte(`{"a":1,"b":2,"c":3}`)); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != want {
+			t.Errorf("fast path %t: got %q, want %q", w.fastPathEnabled(), got, want)
+		}
+	}
+
+	for _, fast := range []bool{true, false} {
+		w := NewKeyValueWriter(WithOutput(&out), WithFastPath(fast), WithKeysExclude("a"))
+		write(w, "b=\"2\" c=\"3\"\n")
+		w.KeysExclude[0] = "b"
+		write(w, "a=\"1\" c=\"3\"\n")
+	}
+
+	w := NewKeyValueWriter(WithOutput(&out), WithFieldsOrder("c"))
+	write(w, "c=\"3\" a=\"1\" b=\"2\"\n")
+	w.FieldsOrder[0] = "b"
+	write(w, "b=\"2\" a=\"1\" c=\"3\"\n")
+}
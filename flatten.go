@@ -0,0 +1,267 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlattenStyle defines how the keys of nested objects are joined when the event is flattened.
+type FlattenStyle int
+
+const (
+	// FlattenDot joins keys with dots, e.g. "http.method".
+	FlattenDot FlattenStyle = iota
+	// FlattenUnderscore joins keys with underscores, e.g. "http_method".
+	FlattenUnderscore
+	// FlattenSlash joins keys with slashes, e.g. "http/method".
+	FlattenSlash
+	// FlattenDoubleColon joins keys with double colons, e.g. "http::method".
+	FlattenDoubleColon
+	// FlattenRails joins keys ala Rails, e.g. "http[method]".
+	FlattenRails
+)
+
+// CollisionMode defines what happens when flattening an event or transforming its keys
+// results in a key that is already set, e.g. "a.b" of {"a.b":1,"a":{"b":2}}.
+type CollisionMode int
+
+const (
+	// CollisionLastWins keeps the value flattened last. Nested values overwrite top-level
+	// ones, which value of colliding nested values is kept is unspecified.
+	CollisionLastWins CollisionMode = iota
+	// CollisionFirstWins keeps the value flattened first.
+	CollisionFirstWins
+	// CollisionSuffix keeps all values, writing the later ones with the key suffixed by
+	// their number, e.g. "a.b#2".
+	CollisionSuffix
+	// CollisionError fails the event with an error matching ErrFlatten.
+	CollisionError
+)
+
+// ArrayMode defines how arrays are written.
+type ArrayMode int
+
+const (
+	// ArrayIndexKeys flattens arrays using element indexes as keys, e.g. "tags.0=a tags.1=b".
+	ArrayIndexKeys ArrayMode = iota
+	// ArrayJoinValues joins the array elements with ArrayJoin, e.g. "tags=a,b".
+	ArrayJoinValues
+	// ArrayRawJSON writes arrays as compact JSON, e.g. 'tags=["a","b"]'.
+	ArrayRawJSON
+)
+
+// keySeparator defines how the keys of nested objects are joined: the parent, Before,
+// Middle, the name and After.
+type keySeparator struct {
+	Before, Middle, After string
+}
+
+// keySeparator returns the key separator of the writer. FlattenSeparator takes
+// precedence over FlattenStyle.
+func (w KeyValueWriter) keySeparator() keySeparator {
+	if w.FlattenSeparator != "" {
+		return keySeparator{Middle: w.FlattenSeparator}
+	}
+	switch w.FlattenStyle {
+	case FlattenUnderscore:
+		return keySeparator{Middle: "_"}
+	case FlattenSlash:
+		return keySeparator{Middle: "/"}
+	case FlattenDoubleColon:
+		return keySeparator{Middle: "::"}
+	case FlattenRails:
+		return keySeparator{Before: "[", After: "]"}
+	default:
+		return keySeparator{Middle: "."}
+	}
+}
+
+// join returns the flattened key of name nested in parent.
+func (s keySeparator) join(parent, name string) string {
+	return parent + s.Before + s.Middle + name + s.After
+}
+
+// joinKey returns the flattened key of name nested in the flattened key parent. An empty
+// parent denotes the top level.
+func (w KeyValueWriter) joinKey(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return w.keySeparator().join(parent, name)
+}
+
+// flattenEvent replaces the objects and arrays of evt in place with their elements, keyed
+// by the joined keys and array indexes, e.g. {"http":{"method":"GET"}} becomes
+// {"http.method":"GET"}. Empty objects and arrays are removed. Events without nested
+// values are left untouched without allocating.
+//
+// Top-level values are flattened first. Except with CollisionLastWins, objects are then
+// flattened in key order, so the handling of colliding keys does not depend on the map
+// iteration order.
+func (w KeyValueWriter) flattenEvent(evt map[string]interface{}) error {
+	var nested []string
+	for k, v := range evt {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			nested = append(nested, k)
+		}
+	}
+	if len(nested) == 0 {
+		return nil
+	}
+
+	f := flattener{flat: evt, sep: w.keySeparator(), mode: w.CollisionMode}
+	if f.mode != CollisionLastWins {
+		sort.Strings(nested)
+	}
+	for _, k := range nested {
+		v := evt[k]
+		delete(evt, k)
+		f.value(k, v)
+	}
+	return f.err
+}
+
+// flattener adds flattened values to flat according to the collision mode.
+type flattener struct {
+	flat map[string]interface{}
+	sep  keySeparator
+	mode CollisionMode
+	err  error
+}
+
+// value adds the value of key, flattening objects and arrays.
+func (f *flattener) value(key string, v interface{}) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if f.mode == CollisionLastWins {
+			for k, elem := range vv {
+				f.value(f.sep.join(key, k), elem)
+			}
+			return
+		}
+		var pooled = getKeys()
+		for k := range vv {
+			*pooled = append(*pooled, k)
+		}
+		sort.Strings(*pooled)
+		for _, k := range *pooled {
+			f.value(f.sep.join(key, k), vv[k])
+		}
+		putKeys(pooled)
+	case []interface{}:
+		for i, elem := range vv {
+			f.value(f.sep.join(key, strconv.Itoa(i)), elem)
+		}
+	default:
+		f.set(key, v)
+	}
+}
+
+// set sets the flattened key to v, unless the key is already set and the collision mode
+// keeps the existing value.
+func (f *flattener) set(key string, v interface{}) {
+	if f.mode == CollisionLastWins {
+		f.flat[key] = v
+		return
+	}
+	if _, ok := f.flat[key]; !ok {
+		f.flat[key] = v
+		return
+	}
+
+	switch f.mode {
+	case CollisionSuffix:
+		for n := 2; ; n++ {
+			k := key + "#" + strconv.Itoa(n)
+			if _, ok := f.flat[k]; !ok {
+				f.flat[k] = v
+				return
+			}
+		}
+	case CollisionError:
+		if f.err == nil {
+			f.err = fmt.Errorf("%w: duplicate key %q", ErrFlatten, key)
+		}
+	}
+}
+
+// limitDepth replaces objects and arrays nested deeper than depth levels in obj with their
+// compact JSON encoding, so they are written as a single value.
+func limitDepth(obj map[string]interface{}, depth int) {
+	for k, v := range obj {
+		obj[k] = limitValueDepth(v, depth-1)
+	}
+}
+
+func limitValueDepth(v interface{}, depth int) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if depth <= 0 {
+			return compactJSON(vv)
+		}
+		limitDepth(vv, depth)
+	case []interface{}:
+		if depth <= 0 {
+			return compactJSON(vv)
+		}
+		for i, elem := range vv {
+			vv[i] = limitValueDepth(elem, depth-1)
+		}
+	}
+	return v
+}
+
+// compactJSON encodes v as json.RawMessage, falling back to v if it cannot be encoded.
+func compactJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	return json.RawMessage(b)
+}
+
+// collapseArrays replaces the arrays in obj according to mode, so they are written as
+// a single value.
+func collapseArrays(obj map[string]interface{}, mode ArrayMode, join string) {
+	for k, v := range obj {
+		obj[k] = collapseValueArrays(v, mode, join)
+	}
+}
+
+func collapseValueArrays(v interface{}, mode ArrayMode, join string) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		collapseArrays(vv, mode, join)
+	case []interface{}:
+		if mode == ArrayRawJSON {
+			return compactJSON(vv)
+		}
+		var elems = make([]string, len(vv))
+		for i, elem := range vv {
+			elems[i] = joinElement(elem)
+		}
+		return strings.Join(elems, join)
+	}
+	return v
+}
+
+// joinElement returns the array element as string. Objects, arrays, booleans and nulls
+// are encoded as compact JSON.
+func joinElement(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case json.Number:
+		return vv.String()
+	default:
+		b, err := json.Marshal(vv)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
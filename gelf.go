@@ -0,0 +1,68 @@
+package kvwriter
+
+import "encoding/json"
+
+// gelfLevels maps the syslog severities used as GELF levels to levels.
+var gelfLevels = [...]Level{
+	LevelEmergency,
+	LevelAlert,
+	LevelCritical,
+	LevelError,
+	LevelWarn,
+	LevelNotice,
+	LevelInfo,
+	LevelDebug,
+}
+
+// decodeGELFEvents decodes GELF messages and converts their fields into the fields of
+// the writer: short_message becomes the message, timestamp the timestamp and the syslog
+// severity of level the level name. The underscore prefix of additional fields is
+// removed, unless the name is already taken, and version is dropped.
+func (w KeyValueWriter) decodeGELFEvents(p []byte) ([]map[string]interface{}, error) {
+	events, err := w.decodeJSONEvents(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, evt := range events {
+		w.normalizeGELF(evt)
+	}
+	return events, nil
+}
+
+func (w KeyValueWriter) normalizeGELF(evt map[string]interface{}) {
+	delete(evt, "version")
+	renameField(evt, "short_message", w.MessageFieldName)
+	renameField(evt, "timestamp", w.TimestampFieldName)
+	if n, ok := evt["level"].(json.Number); ok {
+		if i, err := n.Int64(); err == nil && i >= 0 && i < int64(len(gelfLevels)) {
+			delete(evt, "level")
+			evt[w.LevelFieldName] = gelfLevels[i].String()
+		}
+	}
+
+	var additional []string
+	for key := range evt {
+		if len(key) > 1 && key[0] == '_' {
+			additional = append(additional, key)
+		}
+	}
+	for _, key := range additional {
+		renameField(evt, key, key[1:])
+	}
+}
+
+// renameField renames the field from to to, unless to is already set.
+func renameField(evt map[string]interface{}, from, to string) {
+	if from == to || to == "" {
+		return
+	}
+	v, ok := evt[from]
+	if !ok {
+		return
+	}
+	if _, taken := evt[to]; taken {
+		return
+	}
+	delete(evt, from)
+	evt[to] = v
+}
@@ -1,5 +1,11 @@
 module github.com/milesich/kv-writer
 
-go 1.16
+go 1.21
 
-require github.com/jeremywohl/flatten v1.0.1
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/mattn/go-isatty v0.0.19
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.12.0 // indirect
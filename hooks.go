@@ -0,0 +1,16 @@
+package kvwriter
+
+// Hooks are called around formatting and writing events, e.g. to count or mirror them.
+type Hooks struct {
+	// BeforeWrite is called with every decoded event before it is formatted and may
+	// modify it.
+	//
+	// Deprecated: Use a Transformer in Pipeline, which is called with the flattened event
+	// and may also drop it.
+	BeforeWrite func(evt map[string]interface{})
+
+	// AfterWrite is called with every chunk of output written to Out and the error of
+	// writing it, e.g. all lines of a single Write call, or the whole batch with FlushSize
+	// or FlushInterval. The output must not be retained after it returns.
+	AfterWrite func(output []byte, err error)
+}
@@ -0,0 +1,150 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ByteUnits defines the units of humanized byte sizes.
+type ByteUnits int
+
+const (
+	// ByteUnitsBinary uses powers of 1024: KiB, MiB, GiB, ...
+	ByteUnitsBinary ByteUnits = iota
+	// ByteUnitsDecimal uses powers of 1000: kB, MB, GB, ...
+	ByteUnitsDecimal
+)
+
+var (
+	binaryByteUnits  = [...]string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	decimalByteUnits = [...]string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+)
+
+// lookupDurationUnit returns the unit of the duration key. Exact keys take precedence over
+// glob patterns, of which the longest matching one wins.
+func (w KeyValueWriter) lookupDurationUnit(key string) (time.Duration, bool) {
+	if unit, ok := w.DurationKeys[key]; ok {
+		return unit, true
+	}
+
+	var best string
+	var unit time.Duration
+	for p, u := range w.DurationKeys {
+		if strings.ContainsAny(p, "*?") && matchGlob(p, key) &&
+			(len(p) > len(best) || len(p) == len(best) && p < best) {
+			best, unit = p, u
+		}
+	}
+	return unit, best != ""
+}
+
+// DurationFormatter returns a Formatter rendering numbers in unit as human readable
+// durations with up to precision decimal places, e.g. 1532 milliseconds as 1.53s.
+func DurationFormatter(unit time.Duration, precision int) Formatter {
+	return func(i interface{}) string {
+		f, ok := numberValue(i)
+		if !ok {
+			return defaultFormatValue(i)
+		}
+		return formatDuration(f*float64(unit), precision)
+	}
+}
+
+// ByteSizeFormatter returns a Formatter rendering numbers as human readable byte sizes,
+// e.g. 1048576 as 1.0MiB.
+func ByteSizeFormatter(units ByteUnits) Formatter {
+	return func(i interface{}) string {
+		f, ok := numberValue(i)
+		if !ok {
+			return defaultFormatValue(i)
+		}
+		return formatByteSize(f, units)
+	}
+}
+
+// PercentFormatter returns a Formatter rendering numbers as percentages with up to
+// precision decimal places, e.g. 42.5%.
+func PercentFormatter(precision int) Formatter {
+	return func(i interface{}) string {
+		f, ok := numberValue(i)
+		if !ok {
+			return defaultFormatValue(i)
+		}
+		return formatDecimal(f, precision) + "%"
+	}
+}
+
+// numericFormatter returns a formatter rendering numbers by f and other values by fv.
+func numericFormatter(f, fv Formatter) Formatter {
+	return func(i interface{}) string {
+		if _, ok := numberValue(i); ok {
+			return f(i)
+		}
+		return fv(i)
+	}
+}
+
+// numberValue returns the float value of a number or numeric string.
+func numberValue(i interface{}) (float64, bool) {
+	var s string
+	switch v := i.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+}
+
+// formatDuration renders ns nanoseconds in the largest unit up to seconds with precision
+// decimal places, e.g. 1.53s, and longer durations rounded to seconds, e.g. 2m3s.
+func formatDuration(ns float64, precision int) string {
+	abs := math.Abs(ns)
+	switch {
+	case abs < float64(time.Microsecond):
+		return formatDecimal(ns, precision) + "ns"
+	case abs < float64(time.Millisecond):
+		return formatDecimal(ns/float64(time.Microsecond), precision) + "µs"
+	case abs < float64(time.Second):
+		return formatDecimal(ns/float64(time.Millisecond), precision) + "ms"
+	case abs < float64(time.Minute):
+		return formatDecimal(ns/float64(time.Second), precision) + "s"
+	case abs >= math.MaxInt64:
+		return strconv.FormatFloat(ns/float64(time.Second), 'g', precision+1, 64) + "s"
+	}
+	return time.Duration(ns).Round(time.Second).String()
+}
+
+// formatDecimal formats f with up to precision decimal places, dropping trailing zeros.
+func formatDecimal(f float64, precision int) string {
+	s := strconv.FormatFloat(f, 'f', precision, 64)
+	if strings.IndexByte(s, '.') >= 0 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// formatByteSize renders n bytes in the largest unit not exceeding it with one decimal
+// place, e.g. 1.0MiB. Sizes below the first unit are rendered as whole bytes.
+func formatByteSize(n float64, units ByteUnits) string {
+	var base, names = 1024.0, binaryByteUnits[:]
+	if units == ByteUnitsDecimal {
+		base, names = 1000, decimalByteUnits[:]
+	}
+
+	var i int
+	for abs := math.Abs(n); abs >= base && i < len(names)-1; i++ {
+		abs /= base
+		n /= base
+	}
+	if i == 0 {
+		return strconv.FormatFloat(n, 'f', 0, 64) + names[0]
+	}
+	return strconv.FormatFloat(n, 'f', 1, 64) + names[i]
+}
@@ -0,0 +1,108 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+// maxJournalFieldName is the maximum length of journal field names.
+const maxJournalFieldName = 64
+
+// journalPriorities maps levels to the syslog priorities used by journald.
+var journalPriorities = [...]int{
+	LevelTrace:     7,
+	LevelDebug:     7,
+	LevelInfo:      6,
+	LevelNotice:    5,
+	LevelWarn:      4,
+	LevelError:     3,
+	LevelCritical:  2,
+	LevelAlert:     1,
+	LevelEmergency: 0,
+}
+
+// writeJournal appends the fields of the event to buf in the Journal Export Format. The
+// message is written as MESSAGE and the level is mapped to PRIORITY. Values containing
+// newlines or control characters use the binary framing. The entry is terminated by the
+// empty line written by renderEvent.
+func (w KeyValueWriter) writeJournal(evt map[string]interface{}, buf *bytes.Buffer) {
+	var pooled = w.sortedKeys(evt)
+	defer putKeys(pooled)
+	var keys = *pooled
+
+	_, fv := w.formatters()
+	var priority bool
+	for _, key := range keys {
+		name := journalFieldName(key)
+		if key == w.MessageFieldName {
+			name = "MESSAGE"
+		}
+		priority = priority || name == "PRIORITY"
+
+		s, _ := w.formatValue(key, evt[key], fv)
+		writeJournalField(buf, name, s)
+	}
+
+	if l := w.EventLevel(evt); !priority && l != LevelUnset {
+		writeJournalField(buf, "PRIORITY", strconv.Itoa(journalPriorities[l]))
+	}
+}
+
+// writeJournalField appends a single field to buf.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !journalBinary(value) {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.WriteByte('\n')
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalBinary reports whether the value needs the binary framing.
+func journalBinary(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < ' ' && value[i] != '\t' || value[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// journalFieldName converts key into a valid journal field name of upper-case letters,
+// digits and underscores, e.g. "http.status" to "HTTP_STATUS". Leading underscores are
+// removed as they are reserved for trusted fields and names starting with a digit are
+// prefixed with "FIELD_".
+func journalFieldName(key string) string {
+	var b = make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			c = '_'
+		}
+		if c == '_' && len(b) == 0 {
+			continue
+		}
+		b = append(b, c)
+	}
+
+	if len(b) == 0 || b[0] >= '0' && b[0] <= '9' {
+		b = append([]byte("FIELD_"), b...)
+	}
+	if len(b) > maxJournalFieldName {
+		b = b[:maxJournalFieldName]
+	}
+	return string(b)
+}
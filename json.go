@@ -0,0 +1,167 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode/utf8"
+)
+
+// writeJSON appends the event to buf as a single-line JSON object. Keys are filtered,
+// ordered and formatted like pairs and values are written as JSON strings formatted like
+// pair values, without quotes and colors. Numbers, booleans, nulls, objects and arrays
+// without a value formatter keep their JSON type. In Nested mode, the hierarchy of the
+// event is preserved.
+func (w KeyValueWriter) writeJSON(evt map[string]interface{}, buf *bytes.Buffer) {
+	fk, fv := w.formatters()
+	if w.Nested {
+		w.writeJSONObject(buf, evt, "", fk, fv)
+		return
+	}
+
+	var pooled = w.sortedKeys(evt)
+	defer putKeys(pooled)
+
+	buf.WriteByte('{')
+	for i, key := range *pooled {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, formatKey(fk, key))
+		buf.WriteByte(':')
+		w.writeJSONValue(buf, key, evt[key], fk, fv)
+	}
+	buf.WriteByte('}')
+}
+
+// writeJSONObject appends the kept members of obj nested in the flattened key parent.
+func (w KeyValueWriter) writeJSONObject(buf *bytes.Buffer, obj map[string]interface{}, parent string, fk, fv Formatter) {
+	var paths = make([]string, 0, len(obj))
+	var names = make(map[string]string, len(obj))
+	for name, value := range obj {
+		path := w.joinKey(parent, name)
+		if w.keepNested(path, value) {
+			paths = append(paths, path)
+			names[path] = name
+		}
+	}
+	w.sortKeys(paths)
+
+	buf.WriteByte('{')
+	for i, path := range paths {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, formatKey(fk, names[path]))
+		buf.WriteByte(':')
+		w.writeJSONValue(buf, path, obj[names[path]], fk, fv)
+	}
+	buf.WriteByte('}')
+}
+
+// writeJSONValue appends the value of the flattened key path to buf.
+func (w KeyValueWriter) writeJSONValue(buf *bytes.Buffer, path string, value interface{}, fk, fv Formatter) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			w.writeJSONObject(buf, v, path, fk, fv)
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			buf.WriteByte('[')
+			var written int
+			for i, elem := range v {
+				elemPath := w.joinKey(path, strconv.Itoa(i))
+				if !w.keepNested(elemPath, elem) {
+					continue
+				}
+				if written > 0 {
+					buf.WriteByte(',')
+				}
+				w.writeJSONValue(buf, elemPath, elem, fk, fv)
+				written++
+			}
+			buf.WriteByte(']')
+			return
+		}
+	}
+
+	if w.fieldFormatter(path, fv) == nil {
+		switch v := value.(type) {
+		case json.RawMessage:
+			if json.Valid(v) {
+				buf.Write(v)
+				return
+			}
+		case map[string]interface{}:
+			buf.WriteString("{}")
+			return
+		case []interface{}:
+			buf.WriteString("[]")
+			return
+		}
+	}
+
+	// Nulls, booleans and numbers left unchanged by the formatters stay native JSON.
+	s, _ := w.formatValue(path, value, fv)
+	switch v := value.(type) {
+	case nil:
+		if s == w.nullString() {
+			buf.WriteString("null")
+			return
+		}
+	case bool:
+		if s == w.BoolFormat.Format(v) {
+			buf.WriteString(strconv.FormatBool(v))
+			return
+		}
+	case json.Number:
+		if s == string(v) && isJSONNumber(s) {
+			buf.WriteString(s)
+			return
+		}
+	}
+	writeJSONString(buf, s)
+}
+
+// writeJSONString appends s to buf as a JSON string. Invalid UTF-8 is replaced with
+// U+FFFD.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= ' ' && c != '"' && c != '\\' && c < utf8.RuneSelf {
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(byte(r))
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < ' ':
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hexDigits[r>>4])
+			buf.WriteByte(hexDigits[r&0xf])
+		case r == utf8.RuneError && n == 1:
+			buf.WriteString("\ufffd")
+		case r == '\u2028' || r == '\u2029':
+			// Escaped like encoding/json, as JavaScript does not allow them in strings.
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xf])
+		default:
+			buf.WriteString(s[i : i+n])
+		}
+		i += n
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,28 @@
+package kvwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONNativeValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{"default", nil, `{"a":null,"b":true,"n":1234567}`},
+		{"number format", []Option{WithNumberFormat(NumberFormat{ThousandsSeparator: ","})}, `{"a":null,"b":true,"n":"1,234,567"}`},
+		{"detect epochs", []Option{WithDetectEpochs(true)}, `{"a":null,"b":true,"n":1234567}`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := NewKeyValueWriter(append(tt.options, WithOutput(&out), WithOutputFormat(OutputJSON))...)
+		if _, err := w.Write([]byte(`{"a":null,"b":true,"n":1234567}`)); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != tt.want+"\n" {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
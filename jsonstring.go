@@ -0,0 +1,69 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// JSONStringMode defines how string values holding JSON objects or arrays are written.
+type JSONStringMode int
+
+const (
+	// JSONStringsKeep writes them like any other string.
+	JSONStringsKeep JSONStringMode = iota
+	// JSONStringsExpand decodes them, so their keys are flattened under the parent key
+	// like nested objects.
+	JSONStringsExpand
+	// JSONStringsIndent writes them indented on continuation lines after the pairs, like
+	// StackTraceKeys. Other output formats write them unchanged.
+	JSONStringsIndent
+)
+
+// expandJSONStrings replaces the strings of the event holding JSON objects or arrays
+// with their decoded values, recursively.
+func expandJSONStrings(evt map[string]interface{}) {
+	for k, v := range evt {
+		evt[k] = expandJSONValue(v)
+	}
+}
+
+func expandJSONValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case string:
+		if d, ok := decodeJSONString(vv); ok {
+			return expandJSONValue(d)
+		}
+	case map[string]interface{}:
+		expandJSONStrings(vv)
+	case []interface{}:
+		for i, elem := range vv {
+			vv[i] = expandJSONValue(elem)
+		}
+	}
+	return v
+}
+
+// decodeJSONString decodes s if it holds a single JSON object or array.
+func decodeJSONString(s string) (interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if !startsJSONContainer(s) {
+		return nil, false
+	}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.InputOffset() != int64(len(s)) {
+		return nil, false
+	}
+	return v, true
+}
+
+// isJSONContainer reports whether s holds a single JSON object or array.
+func isJSONContainer(s string) bool {
+	s = strings.TrimSpace(s)
+	return startsJSONContainer(s) && json.Valid([]byte(s))
+}
+
+func startsJSONContainer(s string) bool {
+	return len(s) >= 2 && (s[0] == '{' && s[len(s)-1] == '}' || s[0] == '[' && s[len(s)-1] == ']')
+}
@@ -0,0 +1,188 @@
+package kvwriter
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// KeyCase defines the case keys are converted to.
+type KeyCase int
+
+const (
+	// KeyCaseNone leaves keys unchanged.
+	KeyCaseNone KeyCase = iota
+	// KeyCaseSnake converts keys to snake_case.
+	KeyCaseSnake
+	// KeyCaseCamel converts keys to camelCase.
+	KeyCaseCamel
+	// KeyCaseKebab converts keys to kebab-case.
+	KeyCaseKebab
+	// KeyCaseUpper converts keys to UPPER_SNAKE_CASE.
+	KeyCaseUpper
+)
+
+// transformKeys renames the keys of evt in place by KeysRename, KeyTrimPrefixes and
+// KeyCase. Keys renamed to a key that is already set, e.g. two keys renamed to the same
+// name or "userId" and "user_id" with KeyCaseSnake, are handled by CollisionMode like
+// colliding flattened keys, in the order of their original names.
+func (w KeyValueWriter) transformKeys(evt map[string]interface{}) error {
+	if w.KeyCase == KeyCaseNone && len(w.KeysRename) == 0 && len(w.KeyTrimPrefixes) == 0 {
+		return nil
+	}
+
+	f := flattener{mode: w.CollisionMode}
+	w.renameKeys(evt, w.transformKey, &f)
+	return f.err
+}
+
+// renameKeys renames the keys of m in place, setting the renamed keys with f. In Nested
+// mode the keys of the objects in the values are converted to KeyCase first.
+func (w KeyValueWriter) renameKeys(m map[string]interface{}, rename func(string) string, f *flattener) {
+	var moved = getKeys()
+	defer putKeys(moved)
+	for key, value := range m {
+		if w.Nested && w.KeyCase != KeyCaseNone {
+			w.convertNestedKeys(value, f)
+		}
+		if rename(key) != key {
+			*moved = append(*moved, key)
+		}
+	}
+	if len(*moved) == 0 {
+		return
+	}
+
+	var values = getEvent()
+	defer putEvents([]map[string]interface{}{values})
+	for _, key := range *moved {
+		values[key] = m[key]
+		delete(m, key)
+	}
+
+	sort.Strings(*moved)
+	f.flat = m
+	for _, key := range *moved {
+		f.set(rename(key), values[key])
+	}
+}
+
+// transformKey returns the new name of key. Renamed keys are neither trimmed nor converted
+// to KeyCase.
+func (w KeyValueWriter) transformKey(key string) string {
+	if name, ok := w.KeysRename[key]; ok {
+		return name
+	}
+	for _, prefix := range w.KeyTrimPrefixes {
+		if len(key) > len(prefix) && strings.HasPrefix(key, prefix) {
+			key = key[len(prefix):]
+			break
+		}
+	}
+	if w.KeyCase != KeyCaseNone {
+		return convertCase(key, w.KeyCase)
+	}
+	return key
+}
+
+// convertNestedKeys converts the keys of the objects in value to KeyCase.
+func (w KeyValueWriter) convertNestedKeys(value interface{}, f *flattener) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		w.renameKeys(v, w.convertKey, f)
+	case []interface{}:
+		for _, elem := range v {
+			w.convertNestedKeys(elem, f)
+		}
+	}
+}
+
+func (w KeyValueWriter) convertKey(key string) string {
+	return convertCase(key, w.KeyCase)
+}
+
+// convertCase converts every word of key to the case c. Words are runs of letters and
+// digits split at '_', '-', spaces and case changes. Any other character, such as the
+// flatten separator, is kept as is.
+func convertCase(key string, c KeyCase) string {
+	var b strings.Builder
+	b.Grow(len(key) + 4)
+
+	var segment []rune
+	flush := func() {
+		if len(segment) > 0 {
+			writeWords(&b, splitWords(segment), c)
+			segment = segment[:0]
+		}
+	}
+
+	for _, r := range key {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == ' ' {
+			segment = append(segment, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+
+	return b.String()
+}
+
+// splitWords splits s into words at '_', '-', spaces and case changes, keeping acronyms
+// together, e.g. "HTTPStatusCode" becomes "HTTP", "Status", "Code".
+func splitWords(s []rune) []string {
+	var words []string
+	var start = -1
+	for i, r := range s {
+		if r == '_' || r == '-' || r == ' ' {
+			if start >= 0 {
+				words = append(words, string(s[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := s[i-1]
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			(unicode.IsUpper(prev) && i+1 < len(s) && unicode.IsLower(s[i+1]))) {
+			words = append(words, string(s[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(s[start:]))
+	}
+	return words
+}
+
+func writeWords(b *strings.Builder, words []string, c KeyCase) {
+	for i, word := range words {
+		switch c {
+		case KeyCaseSnake, KeyCaseKebab, KeyCaseUpper:
+			if i > 0 {
+				if c == KeyCaseKebab {
+					b.WriteByte('-')
+				} else {
+					b.WriteByte('_')
+				}
+			}
+			if c == KeyCaseUpper {
+				b.WriteString(strings.ToUpper(word))
+			} else {
+				b.WriteString(strings.ToLower(word))
+			}
+		case KeyCaseCamel:
+			word = strings.ToLower(word)
+			if i > 0 {
+				r := []rune(word)
+				r[0] = unicode.ToUpper(r[0])
+				word = string(r)
+			}
+			b.WriteString(word)
+		}
+	}
+}
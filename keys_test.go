@@ -0,0 +1,54 @@
+package kvwriter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestKeysRenameCollision(t *testing.T) {
+	const in = `{"a":1,"b":2,"c":3}`
+	tests := []struct {
+		mode CollisionMode
+		want string
+	}{
+		{CollisionLastWins, "c=\"2\"\n"},
+		{CollisionFirstWins, "c=\"3\"\n"},
+		{CollisionSuffix, "c=\"3\" c#2=\"1\" c#3=\"2\"\n"},
+		{CollisionError, ""},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := NewKeyValueWriter(WithOutput(&out), WithCollisionMode(tt.mode),
+			WithKeyRename("a", "c"), WithKeyRename("b", "c"))
+		_, err := w.Write([]byte(in))
+		if (tt.mode == CollisionError) != errors.Is(err, ErrFlatten) {
+			t.Errorf("mode %d: unexpected error %v", tt.mode, err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("mode %d: got %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestKeyCaseCollision(t *testing.T) {
+	tests := []struct {
+		nested bool
+		in     string
+		want   string
+	}{
+		{false, `{"user_id":1,"userId":2}`, "user_id=\"1\" user_id#2=\"2\"\n"},
+		{true, `{"user":{"user_id":1,"userId":2}}`, "user={user_id=\"1\" user_id#2=\"2\"}\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := NewKeyValueWriter(WithOutput(&out), WithNested(tt.nested),
+			WithCollisionMode(CollisionSuffix), WithKeyCase(KeyCaseSnake))
+		if _, err := w.Write([]byte(tt.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("nested %t: got %q, want %q", tt.nested, got, tt.want)
+		}
+	}
+}
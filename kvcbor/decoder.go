@@ -0,0 +1,382 @@
+// Package kvcbor decodes CBOR encoded events for KeyValueWriter, such as written by
+// zerolog's binary_log mode.
+package kvcbor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Tags with a special meaning for the decoded value.
+const (
+	tagDateTime     = 0
+	tagEpochTime    = 1
+	tagPositiveBig  = 2
+	tagNegativeBig  = 3
+	tagIPAddress    = 260
+	tagEmbeddedJSON = 262
+	tagHexString    = 263
+)
+
+// Decoder decodes a stream of CBOR maps into events.
+type Decoder struct{}
+
+var _ kvwriter.Decoder = Decoder{}
+
+// NewDecoder creates a Decoder.
+func NewDecoder() Decoder {
+	return Decoder{}
+}
+
+// Decode decodes all maps of p into events. Newlines between the maps are skipped.
+func (d Decoder) Decode(p []byte) ([]map[string]interface{}, error) {
+	var r = reader{b: p}
+	var events []map[string]interface{}
+	for {
+		for r.off < len(r.b) && (r.b[r.off] == '\n' || r.b[r.off] == '\r') {
+			r.off++
+		}
+		if r.off == len(r.b) {
+			break
+		}
+
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		evt, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cbor: value of type %T is not an event", v)
+		}
+		events = append(events, evt)
+	}
+	if len(events) == 0 {
+		return nil, errors.New("cbor: empty input")
+	}
+	return events, nil
+}
+
+// reader reads CBOR values from b. Byte strings are returned as []byte and converted by
+// the enclosing map, array or tag.
+type reader struct {
+	b   []byte
+	off int
+}
+
+var (
+	errShort = errors.New("cbor: unexpected end of input")
+	errBreak = errors.New("cbor: unexpected break")
+)
+
+func (r *reader) next(n uint64) ([]byte, error) {
+	if uint64(len(r.b)-r.off) < n {
+		return nil, errShort
+	}
+	b := r.b[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b, nil
+}
+
+// head reads the major type and argument of the next data item. Indefinite lengths are
+// reported by indefinite.
+func (r *reader) head() (major byte, arg uint64, indefinite bool, err error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info == 31:
+		return major, 0, true, nil
+	case info > 27:
+		return 0, 0, false, fmt.Errorf("cbor: invalid additional information %d at offset %d", info, r.off-1)
+	}
+
+	n := uint64(1) << (info - 24)
+	if b, err = r.next(n); err != nil {
+		return 0, 0, false, err
+	}
+	switch n {
+	case 1:
+		arg = uint64(b[0])
+	case 2:
+		arg = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		arg = uint64(binary.BigEndian.Uint32(b))
+	default:
+		arg = binary.BigEndian.Uint64(b)
+	}
+	return major, arg, false, nil
+}
+
+// isBreak consumes the break code ending an indefinite length item.
+func (r *reader) isBreak() (bool, error) {
+	if r.off >= len(r.b) {
+		return false, errShort
+	}
+	if r.b[r.off] == 0xff {
+		r.off++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *reader) value() (interface{}, error) {
+	start := r.off
+	major, arg, indefinite, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		return json.Number(strconv.FormatUint(arg, 10)), nil
+	case 1:
+		if arg <= math.MaxInt64 {
+			return json.Number(strconv.FormatInt(-1-int64(arg), 10)), nil
+		}
+		n := new(big.Int).SetUint64(arg)
+		return json.Number(n.Neg(n.Add(n, big.NewInt(1))).String()), nil
+	case 2, 3:
+		b, err := r.readBytes(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == 3 {
+			return string(b), nil
+		}
+		return b, nil
+	case 4:
+		return r.readArray(arg, indefinite)
+	case 5:
+		return r.readMap(arg, indefinite)
+	case 6:
+		if indefinite {
+			break
+		}
+		return r.readTag(arg)
+	case 7:
+		return r.readSimple(start, r.b[start]&0x1f, arg)
+	}
+	return nil, fmt.Errorf("cbor: invalid data item at offset %d", start)
+}
+
+// readBytes reads a byte or text string, concatenating the chunks of indefinite lengths.
+func (r *reader) readBytes(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return r.next(n)
+	}
+
+	var buf []byte
+	for {
+		if ok, err := r.isBreak(); err != nil || ok {
+			return buf, err
+		}
+		m, n, ind, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || ind {
+			return nil, fmt.Errorf("cbor: invalid string chunk at offset %d", r.off)
+		}
+		chunk, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, chunk...)
+	}
+}
+
+func (r *reader) readArray(n uint64, indefinite bool) (interface{}, error) {
+	if !indefinite && n > uint64(len(r.b)-r.off) {
+		return nil, errShort
+	}
+
+	var arr = make([]interface{}, 0, n)
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			if ok, err := r.isBreak(); err != nil {
+				return nil, err
+			} else if ok {
+				break
+			}
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, bytesValue(v))
+	}
+	return arr, nil
+}
+
+func (r *reader) readMap(n uint64, indefinite bool) (interface{}, error) {
+	if !indefinite && n > uint64(len(r.b)-r.off)/2 {
+		return nil, errShort
+	}
+
+	var m = make(map[string]interface{}, n)
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			if ok, err := r.isBreak(); err != nil {
+				return nil, err
+			} else if ok {
+				break
+			}
+		}
+		k, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		m[keyString(k)] = bytesValue(v)
+	}
+	return m, nil
+}
+
+// readTag reads a tagged value. Times are returned as RFC 3339 strings, bignums as
+// json.Number, embedded JSON decoded and IP addresses and hex strings formatted.
+func (r *reader) readTag(tag uint64) (interface{}, error) {
+	v, err := r.value()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case tagDateTime:
+		return bytesValue(v), nil
+	case tagEpochTime:
+		if n, ok := v.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				sec, frac := math.Modf(f)
+				return time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano), nil
+			}
+		}
+	case tagPositiveBig, tagNegativeBig:
+		if b, ok := v.([]byte); ok {
+			n := new(big.Int).SetBytes(b)
+			if tag == tagNegativeBig {
+				n.Neg(n.Add(n, big.NewInt(1)))
+			}
+			return json.Number(n.String()), nil
+		}
+	case tagIPAddress:
+		if b, ok := v.([]byte); ok && (len(b) == net.IPv4len || len(b) == net.IPv6len) {
+			return net.IP(b).String(), nil
+		}
+	case tagEmbeddedJSON:
+		if b, ok := v.([]byte); ok {
+			var j interface{}
+			d := json.NewDecoder(bytes.NewReader(b))
+			d.UseNumber()
+			if err := d.Decode(&j); err != nil {
+				return nil, fmt.Errorf("cbor: invalid embedded JSON: %s", err)
+			}
+			return j, nil
+		}
+	case tagHexString:
+		if b, ok := v.([]byte); ok {
+			return hex.EncodeToString(b), nil
+		}
+	}
+	return bytesValue(v), nil
+}
+
+// readSimple reads a simple value or float of the major type 7.
+func (r *reader) readSimple(start int, info byte, arg uint64) (interface{}, error) {
+	switch info {
+	case 25:
+		return floatValue(halfFloat(uint16(arg)), 32), nil
+	case 26:
+		return floatValue(float64(math.Float32frombits(uint32(arg))), 32), nil
+	case 27:
+		return floatValue(math.Float64frombits(arg), 64), nil
+	case 31:
+		return nil, errBreak
+	}
+
+	switch arg {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d at offset %d", arg, start)
+}
+
+// halfFloat converts an IEEE 754 half-precision float to a float64.
+func halfFloat(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// floatValue converts f to a json.Number, or a string if it is not a valid JSON number.
+func floatValue(f float64, bits int) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+}
+
+// bytesValue converts byte strings to strings, base64 encoded unless valid UTF-8.
+func bytesValue(v interface{}) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// keyString converts a map key to a string.
+func keyString(k interface{}) string {
+	switch k := bytesValue(k).(type) {
+	case string:
+		return k
+	case json.Number:
+		return k.String()
+	case nil:
+		return "null"
+	default:
+		b, _ := json.Marshal(k)
+		return string(b)
+	}
+}
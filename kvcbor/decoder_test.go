@@ -0,0 +1,87 @@
+package kvcbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// text encodes s as a short CBOR text string.
+func text(s string) []byte {
+	return append([]byte{0x60 | byte(len(s))}, s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want []map[string]interface{}
+	}{
+		{"scalars", concat([]byte{0xa6},
+			text("msg"), text("hi"),
+			text("n"), []byte{0x20},
+			text("ok"), []byte{0xf5},
+			text("f"), []byte{0xf9, 0x3e, 0x00},
+			text("none"), []byte{0xf6},
+			text("bin"), []byte{0x41, 0xff},
+		), []map[string]interface{}{{
+			"msg": "hi", "n": json.Number("-1"), "ok": true, "f": json.Number("1.5"), "none": nil, "bin": "/w==",
+		}}},
+		{"tags", concat([]byte{0xa4},
+			text("time"), []byte{0xc1, 0x1a, 0x65, 0x53, 0xf1, 0x00},
+			text("ip"), []byte{0xd9, 0x01, 0x04, 0x44, 127, 0, 0, 1},
+			text("json"), []byte{0xd9, 0x01, 0x06, 0x47}, []byte(`{"a":1}`),
+			text("big"), []byte{0xc2, 0x49, 0x01, 0, 0, 0, 0, 0, 0, 0, 0},
+		), []map[string]interface{}{{
+			"time": "2023-11-14T22:13:20Z", "ip": "127.0.0.1",
+			"json": map[string]interface{}{"a": json.Number("1")}, "big": json.Number("18446744073709551616"),
+		}}},
+		{"indefinite lengths", concat([]byte{0xbf}, text("tags"), []byte{0x9f, 0x01, 0x02, 0xff},
+			text("s"), []byte{0x7f}, text("ab"), text("c"), []byte{0xff, 0xff}),
+			[]map[string]interface{}{{"tags": []interface{}{json.Number("1"), json.Number("2")}, "s": "abc"}}},
+		{"lines", concat([]byte{0xa1}, text("a"), []byte{0x01, '\n', 0xa1}, text("a"), []byte{0x02, '\n'}),
+			[]map[string]interface{}{{"a": json.Number("1")}, {"a": json.Number("2")}}},
+	}
+	for _, tt := range tests {
+		got, err := NewDecoder().Decode(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":     nil,
+		"truncated": concat([]byte{0xa1}, text("a")),
+		"scalar":    {0x01},
+		"break":     {0xff},
+		"chunk":     concat([]byte{0xa1}, text("s"), []byte{0x7f, 0x41, 0x00, 0xff}),
+	} {
+		if _, err := NewDecoder().Decode(in); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := kvwriter.NewKeyValueWriter(kvwriter.WithOutput(&out), kvwriter.WithDecoder(Decoder{}))
+	if _, err := w.Write(concat([]byte{0xa2}, text("level"), text("info"), text("code"), []byte{0x18, 0xc8})); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "code=\"200\" level=\"info\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,612 @@
+// Package kvconfig builds a KeyValueWriter from a JSON, YAML or TOML configuration file,
+// so a standard console format can be shared across services and the kvw command:
+//
+//	colors: auto
+//	keys_exclude: [pid, hostname]
+//	keys_rename: {msg: message}
+//	fields_order: [time, level, message]
+//	time_layout: "15:04:05"
+//	redact_rules:
+//	  - pattern: 'password=\S+'
+//	    replacement: password=***
+package kvconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	kvwriter "github.com/milesich/kv-writer"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a configuration file.
+type Format string
+
+// Configuration file formats.
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// Config describes the options of a KeyValueWriter. Unset fields keep the defaults of
+// NewKeyValueWriter. Enumerations are configured by the lower-cased names of their
+// constants, e.g. "snake" for KeyCaseSnake.
+type Config struct {
+	InputFormat       string `json:"input_format"`
+	ErrorMode         string `json:"error_mode"`
+	PassThroughPrefix string `json:"pass_through_prefix"`
+	OutputFormat      string `json:"output_format"`
+
+	Columns       []string `json:"columns"`
+	ExtraColumn   string   `json:"extra_column"`
+	ColumnsHeader bool     `json:"columns_header"`
+	TableRows     int      `json:"table_rows"`
+	ColumnWidths  []int    `json:"column_widths"`
+
+	PairsDelimiter    string `json:"pairs_delimiter"`
+	KeyValueDelimiter string `json:"key_value_delimiter"`
+	QuoteValues       *bool  `json:"quote_values"`
+	PreserveTypes     bool   `json:"preserve_types"`
+	QuoteMode         string `json:"quote_mode"`
+	QuoteKeys         bool   `json:"quote_keys"`
+	QuoteChar         string `json:"quote_char"`
+	LogfmtMode        bool   `json:"logfmt_mode"`
+	Template          string `json:"template"`
+
+	KeysInclude []string `json:"keys_include"`
+	KeysExclude []string `json:"keys_exclude"`
+	FieldsOrder []string `json:"fields_order"`
+	AlignValues bool     `json:"align_values"`
+	Multiline   bool     `json:"multiline"`
+
+	JSONStrings            string   `json:"json_strings"`
+	ErrorKeys              []string `json:"error_keys"`
+	ExpandErrors           bool     `json:"expand_errors"`
+	StackTraceKeys         []string `json:"stack_trace_keys"`
+	StackTracePathSegments int      `json:"stack_trace_path_segments"`
+
+	FlattenStyle     string `json:"flatten_style"`
+	FlattenSeparator string `json:"flatten_separator"`
+	CollisionMode    string `json:"collision_mode"`
+	MaxDepth         int    `json:"max_depth"`
+	Nested           bool   `json:"nested"`
+
+	KeyCase         string            `json:"key_case"`
+	KeysRename      map[string]string `json:"keys_rename"`
+	KeyTrimPrefixes []string          `json:"key_trim_prefixes"`
+
+	MaxValueLength int    `json:"max_value_length"`
+	Ellipsis       string `json:"ellipsis"`
+	MaxLineLength  int    `json:"max_line_length"`
+	LineOverflow   string `json:"line_overflow"`
+
+	TimestampFieldName string `json:"timestamp_field"`
+	TimeLayout         string `json:"time_layout"`
+	LevelFieldName     string `json:"level_field"`
+	MinLevel           string `json:"min_level"`
+	AbbreviateLevels   bool   `json:"abbreviate_levels"`
+	LevelWidth         int    `json:"level_width"`
+	LevelIcons         string `json:"level_icons"`
+	MessageFieldName   string `json:"message_field"`
+	UnquotedMessage    bool   `json:"unquoted_message"`
+	CallerFieldName    string `json:"caller_field"`
+	CallerPathSegments int    `json:"caller_path_segments"`
+	Dedup              bool   `json:"dedup"`
+
+	Colors           string            `json:"colors"`
+	KeyColor         *string           `json:"key_color"`
+	ValueColor       string            `json:"value_color"`
+	LevelColors      map[string]string `json:"level_colors"`
+	LevelIconSymbols map[string]string `json:"level_icon_symbols"`
+	Theme            string            `json:"theme"`
+	TrueColor        bool              `json:"true_color"`
+
+	RedactRules    []RedactRule `json:"redact_rules"`
+	RedactBuiltins []string     `json:"redact_builtins"`
+	RedactKeys     []string     `json:"redact_keys"`
+	RedactMask     string       `json:"redact_mask"`
+	HashKeys       []string     `json:"hash_keys"`
+	HashSalt       string       `json:"hash_salt"`
+
+	DurationKeys      map[string][]string `json:"duration_keys"`
+	DurationPrecision *int                `json:"duration_precision"`
+	ByteSizeKeys      []string            `json:"byte_size_keys"`
+	DecimalByteUnits  bool                `json:"decimal_byte_units"`
+	EpochKeys         []string            `json:"epoch_keys"`
+	DetectEpochs      bool                `json:"detect_epochs"`
+	UnitFormatters    bool                `json:"unit_formatters"`
+	WebUnitFormatters bool                `json:"web_unit_formatters"`
+
+	BoolFormat         string `json:"bool_format"`
+	NullMode           string `json:"null_mode"`
+	NullText           string `json:"null_text"`
+	OmitEmpty          bool   `json:"omit_empty"`
+	Decimals           *int   `json:"decimals"`
+	ThousandsSeparator string `json:"thousands_separator"`
+	ExpandExponent     bool   `json:"expand_exponent"`
+}
+
+// RedactRule is a redaction rule replacing the matches of the regular expression Pattern
+// by Replacement.
+type RedactRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// LoadConfig reads the configuration file at path and creates a KeyValueWriter from it.
+// The options are applied after the configuration, e.g. to set the output.
+func LoadConfig(path string, options ...kvwriter.Option) (kvwriter.KeyValueWriter, error) {
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		return kvwriter.KeyValueWriter{}, err
+	}
+	return cfg.NewKeyValueWriter(options...)
+}
+
+// ReadConfig reads the configuration file at path. The format is detected by the file
+// extension: .json, .yaml, .yml or .toml.
+func ReadConfig(path string) (Config, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = FormatJSON
+	case ".yaml", ".yml":
+		format = FormatYAML
+	case ".toml":
+		format = FormatTOML
+	default:
+		return Config{}, fmt.Errorf("kvconfig: unknown format of %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("kvconfig: %s", err)
+	}
+	cfg, err := ParseConfig(data, format)
+	if err != nil {
+		return Config{}, fmt.Errorf("kvconfig: %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig parses a configuration in the given format. Unknown keys are reported as
+// errors.
+func ParseConfig(data []byte, format Format) (Config, error) {
+	var v interface{}
+	switch format {
+	case FormatJSON:
+		v = json.RawMessage(data)
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return Config{}, err
+		}
+	case FormatTOML:
+		var m map[string]interface{}
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return Config{}, err
+		}
+		v = m
+	default:
+		return Config{}, fmt.Errorf("unknown format %q", format)
+	}
+
+	// YAML and TOML are converted to JSON to decode all formats by the same field tags.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// NewKeyValueWriter creates a KeyValueWriter from the configuration, followed by the
+// options. Like kvwriter.New, it reports an invalid configuration as an error.
+func (c Config) NewKeyValueWriter(options ...kvwriter.Option) (kvwriter.KeyValueWriter, error) {
+	opts, err := c.Options()
+	if err != nil {
+		return kvwriter.KeyValueWriter{}, err
+	}
+	return kvwriter.New(append(opts, options...)...)
+}
+
+// Options converts the configuration into writer options.
+func (c Config) Options() ([]kvwriter.Option, error) {
+	var opts []kvwriter.Option
+	var errs []error
+	add := func(opt kvwriter.Option) {
+		opts = append(opts, opt)
+	}
+	enum := func(name, value string, names map[string]int, set func(int)) {
+		if value == "" {
+			return
+		}
+		if v, ok := names[value]; ok {
+			set(v)
+			return
+		}
+		errs = append(errs, fmt.Errorf("unknown %s %q", name, value))
+	}
+
+	enum("input format", c.InputFormat, inputFormats, func(v int) {
+		add(kvwriter.WithInputFormat(kvwriter.InputFormat(v)))
+	})
+	enum("error mode", c.ErrorMode, errorModes, func(v int) {
+		add(kvwriter.WithErrorMode(kvwriter.ErrorMode(v)))
+	})
+	if c.PassThroughPrefix != "" {
+		add(func(w *kvwriter.KeyValueWriter) { w.PassThroughPrefix = c.PassThroughPrefix })
+	}
+	enum("output format", c.OutputFormat, outputFormats, func(v int) {
+		add(kvwriter.WithOutputFormat(kvwriter.OutputFormat(v)))
+	})
+	if len(c.Columns) > 0 {
+		add(kvwriter.WithColumns(c.Columns...))
+	}
+	if c.ExtraColumn != "" {
+		add(kvwriter.WithExtraColumn(c.ExtraColumn))
+	}
+	if c.ColumnsHeader {
+		add(kvwriter.WithColumnsHeader(true))
+	}
+	if c.TableRows != 0 {
+		add(kvwriter.WithTableRows(c.TableRows))
+	}
+	if len(c.ColumnWidths) > 0 {
+		add(kvwriter.WithColumnWidths(c.ColumnWidths...))
+	}
+
+	if c.PairsDelimiter != "" {
+		add(kvwriter.WithPairsSeparator(c.PairsDelimiter))
+	}
+	if c.KeyValueDelimiter != "" {
+		add(kvwriter.WithKeyValueSeparator(c.KeyValueDelimiter))
+	}
+	if c.QuoteValues != nil {
+		add(kvwriter.WithQuoteValues(*c.QuoteValues))
+	}
+	if c.PreserveTypes {
+		add(kvwriter.WithPreserveTypes(true))
+	}
+	enum("quote mode", c.QuoteMode, quoteModes, func(v int) {
+		add(kvwriter.WithQuoteMode(kvwriter.QuoteMode(v)))
+	})
+	if c.Template != "" {
+		add(kvwriter.WithTemplate(c.Template))
+	}
+	if c.QuoteKeys {
+		add(kvwriter.WithQuoteKeys(true))
+	}
+	if c.QuoteChar != "" {
+		if r, n := utf8.DecodeRuneInString(c.QuoteChar); n == len(c.QuoteChar) {
+			add(kvwriter.WithQuoteChar(r))
+		} else {
+			errs = append(errs, fmt.Errorf("invalid quote char %q", c.QuoteChar))
+		}
+	}
+	if c.LogfmtMode {
+		add(kvwriter.WithLogfmtMode(true))
+	}
+
+	if len(c.KeysInclude) > 0 {
+		add(kvwriter.WithKeysInclude(c.KeysInclude...))
+	}
+	if len(c.KeysExclude) > 0 {
+		add(kvwriter.WithKeysExclude(c.KeysExclude...))
+	}
+	if len(c.FieldsOrder) > 0 {
+		add(kvwriter.WithFieldsOrder(c.FieldsOrder...))
+	}
+	if c.AlignValues {
+		add(kvwriter.WithAlignValues(true, 0))
+	}
+	if c.Multiline {
+		add(func(w *kvwriter.KeyValueWriter) { w.Multiline = true })
+	}
+	enum("JSON strings", c.JSONStrings, jsonStringModes, func(v int) {
+		add(kvwriter.WithJSONStrings(kvwriter.JSONStringMode(v)))
+	})
+	if len(c.ErrorKeys) > 0 {
+		add(kvwriter.WithErrorKeys(c.ErrorKeys...))
+	}
+	if c.ExpandErrors {
+		add(kvwriter.WithExpandedErrors(true))
+	}
+	if len(c.StackTraceKeys) > 0 {
+		add(kvwriter.WithStackTraceKeys(c.StackTraceKeys...))
+	}
+	if c.StackTracePathSegments != 0 {
+		add(kvwriter.WithStackTracePathSegments(c.StackTracePathSegments))
+	}
+
+	enum("flatten style", c.FlattenStyle, flattenStyles, func(v int) {
+		add(kvwriter.WithFlattenStyle(kvwriter.FlattenStyle(v)))
+	})
+	if c.FlattenSeparator != "" {
+		add(kvwriter.WithFlattenSeparator(c.FlattenSeparator))
+	}
+	enum("collision mode", c.CollisionMode, collisionModes, func(v int) {
+		add(kvwriter.WithCollisionMode(kvwriter.CollisionMode(v)))
+	})
+	if c.MaxDepth != 0 {
+		add(kvwriter.WithMaxDepth(c.MaxDepth))
+	}
+	if c.Nested {
+		add(kvwriter.WithNested(true))
+	}
+
+	enum("key case", c.KeyCase, keyCases, func(v int) {
+		add(kvwriter.WithKeyCase(kvwriter.KeyCase(v)))
+	})
+	for from, to := range c.KeysRename {
+		add(kvwriter.WithKeyRename(from, to))
+	}
+	if len(c.KeyTrimPrefixes) > 0 {
+		add(kvwriter.WithKeyTrimPrefixes(c.KeyTrimPrefixes...))
+	}
+
+	if c.MaxValueLength != 0 {
+		add(func(w *kvwriter.KeyValueWriter) { w.MaxValueLength = c.MaxValueLength })
+	}
+	if c.Ellipsis != "" {
+		add(func(w *kvwriter.KeyValueWriter) { w.Ellipsis = c.Ellipsis })
+	}
+	if c.MaxLineLength != 0 {
+		add(func(w *kvwriter.KeyValueWriter) { w.MaxLineLength = c.MaxLineLength })
+	}
+	enum("line overflow", c.LineOverflow, lineOverflows, func(v int) {
+		add(func(w *kvwriter.KeyValueWriter) { w.LineOverflow = kvwriter.LineOverflow(v) })
+	})
+
+	if c.TimestampFieldName != "" {
+		add(kvwriter.WithTimestampFieldName(c.TimestampFieldName))
+	}
+	if c.TimeLayout != "" {
+		add(kvwriter.WithTimeLayout(c.TimeLayout))
+	}
+	if c.LevelFieldName != "" {
+		add(kvwriter.WithLevelFieldName(c.LevelFieldName))
+	}
+	if c.MinLevel != "" {
+		if l, ok := kvwriter.ParseLevel(c.MinLevel); ok {
+			add(kvwriter.WithMinLevel(l))
+		} else {
+			errs = append(errs, fmt.Errorf("unknown min level %q", c.MinLevel))
+		}
+	}
+	if c.AbbreviateLevels {
+		add(kvwriter.WithAbbreviatedLevels(true))
+	}
+	if c.LevelWidth != 0 {
+		add(kvwriter.WithLevelWidth(c.LevelWidth))
+	}
+	enum("level icons", c.LevelIcons, levelIconModes, func(v int) {
+		add(kvwriter.WithLevelIcons(kvwriter.LevelIconMode(v)))
+	})
+	for level, icon := range c.LevelIconSymbols {
+		add(kvwriter.WithLevelIcon(level, icon))
+	}
+	if c.MessageFieldName != "" {
+		add(kvwriter.WithMessageFieldName(c.MessageFieldName))
+	}
+	if c.UnquotedMessage {
+		add(kvwriter.WithUnquotedMessage(true))
+	}
+	if c.CallerFieldName != "" {
+		add(kvwriter.WithCallerFieldName(c.CallerFieldName))
+	}
+	if c.CallerPathSegments != 0 {
+		add(kvwriter.WithCallerPathSegments(c.CallerPathSegments))
+	}
+	if c.Dedup {
+		add(kvwriter.WithDedup(true))
+	}
+
+	enum("color mode", c.Colors, colorModes, func(v int) {
+		add(kvwriter.WithColorize(kvwriter.ColorMode(v)))
+	})
+	if c.KeyColor != nil {
+		add(kvwriter.WithKeyColor(kvwriter.Color(*c.KeyColor)))
+	}
+	if c.ValueColor != "" {
+		add(kvwriter.WithValueColor(kvwriter.Color(c.ValueColor)))
+	}
+	for level, color := range c.LevelColors {
+		add(kvwriter.WithLevelColor(level, kvwriter.Color(color)))
+	}
+	if c.Theme != "" {
+		var depth = kvwriter.ColorDepthBasic
+		if c.TrueColor {
+			depth = kvwriter.ColorDepthTrueColor
+		}
+		switch c.Theme {
+		case "dark":
+			add(kvwriter.WithTheme(kvwriter.DarkTheme(depth)))
+		case "light":
+			add(kvwriter.WithTheme(kvwriter.LightTheme(depth)))
+		case "monochrome":
+			add(kvwriter.WithTheme(kvwriter.MonochromeTheme()))
+		default:
+			errs = append(errs, fmt.Errorf("unknown theme %q", c.Theme))
+		}
+	}
+
+	for _, r := range c.RedactRules {
+		rule, err := kvwriter.NewRedactionRule(r.Pattern, r.Replacement)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		add(kvwriter.WithRedactValues(rule))
+	}
+	for _, name := range c.RedactBuiltins {
+		if rule, ok := redactBuiltins[name]; ok {
+			add(kvwriter.WithRedactValues(rule))
+		} else {
+			errs = append(errs, fmt.Errorf("unknown redaction rule %q", name))
+		}
+	}
+	if len(c.RedactKeys) > 0 {
+		add(kvwriter.WithRedactKeys(c.RedactKeys...))
+	}
+	if c.RedactMask != "" {
+		add(kvwriter.WithRedactMask(c.RedactMask))
+	}
+	if len(c.HashKeys) > 0 {
+		add(kvwriter.WithHashKeys(c.HashSalt, c.HashKeys...))
+	}
+
+	for unit, keys := range c.DurationKeys {
+		d, err := time.ParseDuration("1" + unit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unknown duration unit %q", unit))
+			continue
+		}
+		add(kvwriter.WithDurationKeys(d, keys...))
+	}
+	if c.DurationPrecision != nil {
+		add(kvwriter.WithDurationPrecision(*c.DurationPrecision))
+	}
+	if len(c.ByteSizeKeys) > 0 {
+		add(kvwriter.WithByteSizeKeys(c.ByteSizeKeys...))
+	}
+	if c.DecimalByteUnits {
+		add(kvwriter.WithByteUnits(kvwriter.ByteUnitsDecimal))
+	}
+	if len(c.EpochKeys) > 0 {
+		add(kvwriter.WithEpochKeys(c.EpochKeys...))
+	}
+	if c.DetectEpochs {
+		add(kvwriter.WithDetectEpochs(true))
+	}
+	if c.UnitFormatters {
+		add(kvwriter.WithDefaultUnitFormatters())
+	}
+	if c.WebUnitFormatters {
+		add(kvwriter.WithWebUnitFormatters())
+	}
+
+	enum("bool format", c.BoolFormat, boolFormats, func(v int) {
+		add(kvwriter.WithBoolFormat(kvwriter.BoolFormat(v)))
+	})
+	enum("null mode", c.NullMode, nullModes, func(v int) {
+		add(kvwriter.WithNullMode(kvwriter.NullMode(v)))
+	})
+	if c.NullText != "" {
+		add(kvwriter.WithNullPlaceholder(c.NullText))
+	}
+	if c.OmitEmpty {
+		add(kvwriter.WithOmitEmpty(true))
+	}
+	if c.Decimals != nil || c.ThousandsSeparator != "" || c.ExpandExponent {
+		var f = kvwriter.NumberFormat{
+			ThousandsSeparator: c.ThousandsSeparator,
+			ExpandExponent:     c.ExpandExponent,
+		}
+		if c.Decimals != nil {
+			f.FixedDecimals, f.Decimals = true, *c.Decimals
+		}
+		add(kvwriter.WithNumberFormat(f))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("kvconfig: %w", err)
+	}
+	return opts, nil
+}
+
+// Names of the enumerations.
+var (
+	inputFormats = map[string]int{
+		"json":   int(kvwriter.InputJSON),
+		"logfmt": int(kvwriter.InputLogfmt),
+		"auto":   int(kvwriter.InputAuto),
+		"gelf":   int(kvwriter.InputGELF),
+	}
+	errorModes = map[string]int{
+		"fail":         int(kvwriter.ErrorFail),
+		"drop":         int(kvwriter.ErrorDrop),
+		"pass_through": int(kvwriter.ErrorPassThrough),
+	}
+	outputFormats = map[string]int{
+		"key_value": int(kvwriter.OutputKeyValue),
+		"journal":   int(kvwriter.OutputJournal),
+		"json":      int(kvwriter.OutputJSON),
+		"csv":       int(kvwriter.OutputCSV),
+		"tsv":       int(kvwriter.OutputTSV),
+		"table":     int(kvwriter.OutputTable),
+		"values":    int(kvwriter.OutputValues),
+	}
+	quoteModes = map[string]int{
+		"default": int(kvwriter.QuoteDefault),
+		"auto":    int(kvwriter.QuoteAuto),
+	}
+	jsonStringModes = map[string]int{
+		"keep":   int(kvwriter.JSONStringsKeep),
+		"expand": int(kvwriter.JSONStringsExpand),
+		"indent": int(kvwriter.JSONStringsIndent),
+	}
+	levelIconModes = map[string]int{
+		"off":     int(kvwriter.LevelIconsOff),
+		"prefix":  int(kvwriter.LevelIconsPrefix),
+		"replace": int(kvwriter.LevelIconsReplace),
+	}
+	flattenStyles = map[string]int{
+		"dot":          int(kvwriter.FlattenDot),
+		"underscore":   int(kvwriter.FlattenUnderscore),
+		"slash":        int(kvwriter.FlattenSlash),
+		"double_colon": int(kvwriter.FlattenDoubleColon),
+		"rails":        int(kvwriter.FlattenRails),
+	}
+	collisionModes = map[string]int{
+		"last_wins":  int(kvwriter.CollisionLastWins),
+		"first_wins": int(kvwriter.CollisionFirstWins),
+		"suffix":     int(kvwriter.CollisionSuffix),
+		"error":      int(kvwriter.CollisionError),
+	}
+	keyCases = map[string]int{
+		"none":  int(kvwriter.KeyCaseNone),
+		"snake": int(kvwriter.KeyCaseSnake),
+		"camel": int(kvwriter.KeyCaseCamel),
+		"kebab": int(kvwriter.KeyCaseKebab),
+		"upper": int(kvwriter.KeyCaseUpper),
+	}
+	lineOverflows = map[string]int{
+		"wrap":  int(kvwriter.OverflowWrap),
+		"elide": int(kvwriter.OverflowElide),
+	}
+	colorModes = map[string]int{
+		"never":  int(kvwriter.ColorNever),
+		"always": int(kvwriter.ColorAlways),
+		"auto":   int(kvwriter.ColorAuto),
+	}
+	boolFormats = map[string]int{
+		"true_false": int(kvwriter.BoolTrueFalse),
+		"yes_no":     int(kvwriter.BoolYesNo),
+		"check_mark": int(kvwriter.BoolCheckMark),
+		"one_zero":   int(kvwriter.BoolOneZero),
+	}
+	nullModes = map[string]int{
+		"literal":     int(kvwriter.NullLiteral),
+		"omit":        int(kvwriter.NullOmit),
+		"empty":       int(kvwriter.NullEmpty),
+		"placeholder": int(kvwriter.NullPlaceholder),
+	}
+	redactBuiltins = map[string]kvwriter.RedactionRule{
+		"credit_cards":  kvwriter.RedactCreditCards,
+		"bearer_tokens": kvwriter.RedactBearerTokens,
+	}
+)
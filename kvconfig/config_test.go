@@ -0,0 +1,117 @@
+package kvconfig
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+var configs = map[string]string{
+	"config.json": `{
+	"keys_exclude": ["pid"],
+	"keys_rename": {"msg": "message"},
+	"fields_order": ["level", "message"],
+	"quote_values": false,
+	"bool_format": "yes_no",
+	"decimals": 1,
+	"redact_rules": [{"pattern": "password=\\S+", "replacement": "password=***"}]
+}`,
+	"config.yaml": `
+keys_exclude: [pid]
+keys_rename: {msg: message}
+fields_order: [level, message]
+quote_values: false
+bool_format: yes_no
+decimals: 1
+redact_rules:
+  - pattern: 'password=\S+'
+    replacement: password=***
+`,
+	"config.toml": `
+keys_exclude = ["pid"]
+fields_order = ["level", "message"]
+quote_values = false
+bool_format = "yes_no"
+decimals = 1
+
+[keys_rename]
+msg = "message"
+
+[[redact_rules]]
+pattern = 'password=\S+'
+replacement = "password=***"
+`,
+}
+
+func TestLoadConfig(t *testing.T) {
+	var dir = t.TempDir()
+	var want Config
+	for name, data := range configs {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := ReadConfig(path)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if want.BoolFormat == "" {
+			want = cfg
+		} else if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: got %+v, want %+v", name, cfg, want)
+		}
+
+		var out bytes.Buffer
+		w, err := LoadConfig(path, kvwriter.WithOutput(&out))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if _, err := w.Write([]byte(`{"pid":1,"msg":"login password=secret","level":"info","ok":true,"n":2.54}`)); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+		if got, want := out.String(), "level=info message=login password=*** n=2.5 ok=yes\n"; got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		data   string
+		format Format
+	}{
+		{`{"unknown": 1}`, FormatJSON},
+		{`keys_exclude: pid`, FormatYAML},
+		{`keys_exclude = [`, FormatTOML},
+		{`{}`, Format("ini")},
+	}
+	for _, tt := range tests {
+		if _, err := ParseConfig([]byte(tt.data), tt.format); err == nil {
+			t.Errorf("ParseConfig(%q, %q): no error", tt.data, tt.format)
+		}
+	}
+
+	for _, cfg := range []Config{
+		{KeyCase: "pascal"},
+		{MinLevel: "verbose"},
+		{QuoteChar: "ab"},
+		{Theme: "solarized"},
+		{RedactRules: []RedactRule{{Pattern: "("}}},
+		{DurationKeys: map[string][]string{"days": {"ttl"}}},
+	} {
+		if _, err := cfg.NewKeyValueWriter(); err == nil {
+			t.Errorf("%+v: no error", cfg)
+		}
+	}
+
+	if _, err := ReadConfig("config.ini"); err == nil {
+		t.Error("config.ini: no error")
+	}
+}
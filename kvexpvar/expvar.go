@@ -0,0 +1,16 @@
+// Package kvexpvar publishes the Stats of a KeyValueWriter as an expvar variable.
+package kvexpvar
+
+import (
+	"expvar"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Publish publishes the Stats of w under name. Like expvar.Publish, it panics if the name
+// is already registered.
+func Publish(name string, w kvwriter.KeyValueWriter) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return w.Stats()
+	}))
+}
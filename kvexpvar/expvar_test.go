@@ -0,0 +1,26 @@
+package kvexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"testing"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+func TestPublish(t *testing.T) {
+	w := kvwriter.NewKeyValueWriter(kvwriter.WithOutput(io.Discard))
+	Publish("kvwriter_test", w)
+	if _, err := w.Write([]byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got kvwriter.Stats
+	if err := json.Unmarshal([]byte(expvar.Get("kvwriter_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != w.Stats() || got.EventsWritten != 1 {
+		t.Errorf("published %+v, want %+v", got, w.Stats())
+	}
+}
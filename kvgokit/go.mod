@@ -0,0 +1,14 @@
+module github.com/milesich/kv-writer/kvgokit
+
+go 1.21
+
+require (
+	github.com/go-kit/log v0.2.1
+	github.com/milesich/kv-writer v0.1.0
+)
+
+require (
+	github.com/go-logfmt/logfmt v0.5.1 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+)
@@ -0,0 +1,26 @@
+// Package kvgokit adapts KeyValueWriter to go-kit's log.Logger.
+package kvgokit
+
+import (
+	"github.com/go-kit/log"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Logger is a log.Logger writing keyvals straight through a KeyValueWriter without
+// encoding them to JSON first.
+type Logger struct {
+	w kvwriter.KeyValueWriter
+}
+
+var _ log.Logger = Logger{}
+
+// NewLogger creates a log.Logger writing through w.
+func NewLogger(w kvwriter.KeyValueWriter) Logger {
+	return Logger{w: w}
+}
+
+// Log writes the keyvals as a single event.
+func (l Logger) Log(keyvals ...interface{}) error {
+	return l.w.WriteKeyvals(keyvals...)
+}
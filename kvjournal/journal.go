@@ -0,0 +1,112 @@
+// Package kvjournal sends events written by KeyValueWriter in the Journal Export Format to
+// the journald native socket, so their fields arrive in the journal as native fields:
+//
+//	j, err := kvjournal.Dial("")
+//	if err != nil {
+//		return err
+//	}
+//	w := kvwriter.NewKeyValueWriter(
+//		kvwriter.WithOutput(j),
+//		kvwriter.WithOutputFormat(kvwriter.OutputJournal),
+//	)
+package kvjournal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// DefaultSocket is the path of the journald native socket.
+const DefaultSocket = "/run/systemd/journal/socket"
+
+// errIncomplete is returned for input ending in the middle of an entry.
+var errIncomplete = errors.New("kvjournal: incomplete journal entry")
+
+// Writer is an io.WriteCloser sending every journal entry written to it as a datagram to
+// journald. Entries must be written whole, as done by KeyValueWriter. Entries exceeding
+// the maximum datagram size of the socket cannot be sent. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// Dial connects to the journald socket at path, or DefaultSocket if empty.
+func Dial(path string) (*Writer, error) {
+	if path == "" {
+		path = DefaultSocket
+	}
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return nil, fmt.Errorf("kvjournal: %s", err)
+	}
+	return &Writer{conn: conn}, nil
+}
+
+// Write splits p into journal entries and sends each of them.
+func (j *Writer) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for rest := p; len(rest) > 0; {
+		n, err := entryEnd(rest)
+		if err != nil {
+			return len(p) - len(rest), err
+		}
+		if entry := bytes.TrimSuffix(rest[:n], []byte("\n")); len(entry) > 0 {
+			if _, err := j.conn.Write(entry); err != nil {
+				return len(p) - len(rest), fmt.Errorf("kvjournal: %s", err)
+			}
+		}
+		rest = rest[n:]
+	}
+	return len(p), nil
+}
+
+// Close closes the connection.
+func (j *Writer) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.conn.Close()
+}
+
+// entryEnd returns the length of the first entry in p including the empty line ending it.
+// Text fields are "NAME=value\n", binary fields "NAME\n" followed by the little-endian
+// 64-bit length, the value and "\n".
+func entryEnd(p []byte) (int, error) {
+	var i int
+	for i < len(p) {
+		nl := bytes.IndexByte(p[i:], '\n')
+		if nl < 0 {
+			return 0, errIncomplete
+		}
+		line := p[i : i+nl]
+		i += nl + 1
+
+		switch {
+		case len(line) == 0:
+			return i, nil
+		case bytes.IndexByte(line, '=') >= 0:
+		default:
+			if len(p)-i < 8 {
+				return 0, errIncomplete
+			}
+			size := binary.LittleEndian.Uint64(p[i : i+8])
+			if size > uint64(len(p)-i-8) {
+				return 0, errIncomplete
+			}
+			i += 8 + int(size)
+			if i >= len(p) || p[i] != '\n' {
+				return 0, errIncomplete
+			}
+			i++
+		}
+	}
+	return i, nil
+}
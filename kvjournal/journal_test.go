@@ -0,0 +1,70 @@
+package kvjournal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	j, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	w := kvwriter.NewKeyValueWriter(kvwriter.WithOutput(j), kvwriter.WithOutputFormat(kvwriter.OutputJournal))
+	if _, err := w.Write([]byte("{\"level\":\"error\",\"message\":\"a\\nb\"}\n{\"message\":\"c\",\"user.id\":7}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var multiline bytes.Buffer
+	multiline.WriteString("LEVEL=error\nMESSAGE\n")
+	_ = binary.Write(&multiline, binary.LittleEndian, uint64(3))
+	multiline.WriteString("a\nb\nPRIORITY=3\n")
+	for _, want := range []string{multiline.String(), "MESSAGE=c\nUSER_ID=7\n"} {
+		var b = make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(b[:n]); got != want {
+			t.Errorf("got datagram %q, want %q", got, want)
+		}
+	}
+}
+
+func TestEntryEnd(t *testing.T) {
+	var bin = "DATA\n\x02\x00\x00\x00\x00\x00\x00\x00ab\n\n"
+	tests := []struct {
+		in   string
+		n    int
+		fail bool
+	}{
+		{"A=1\nB=2\n\nC=3\n\n", len("A=1\nB=2\n\n"), false},
+		{bin + "A=1\n\n", len(bin), false},
+		{"A=1", 0, true},
+		{"DATA\n\x05\x00\x00\x00\x00\x00\x00\x00ab", 0, true},
+		{"DATA\n\x01\x00\x00\x00\x00\x00\x00\x00ab\n", 0, true},
+	}
+	for _, tt := range tests {
+		n, err := entryEnd([]byte(tt.in))
+		if tt.fail != errors.Is(err, errIncomplete) || n != tt.n {
+			t.Errorf("%q: got %d, %v", tt.in, n, err)
+		}
+	}
+}
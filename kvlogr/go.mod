@@ -0,0 +1,13 @@
+module github.com/milesich/kv-writer/kvlogr
+
+go 1.21
+
+require (
+	github.com/go-logr/logr v1.4.2
+	github.com/milesich/kv-writer v0.1.0
+)
+
+require (
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+)
@@ -0,0 +1,106 @@
+// Package kvlogr adapts KeyValueWriter to logr.LogSink.
+package kvlogr
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+const (
+	// ErrorKey is the key of the error passed to Error.
+	ErrorKey = "error"
+	// NameKey is the key of the logger name, joined with "/".
+	NameKey = "logger"
+	// VerbosityKey is the key of the verbosity of Info messages above 0.
+	VerbosityKey = "v"
+)
+
+// Sink is a logr.LogSink writing keyvals straight through a KeyValueWriter without
+// encoding them to JSON first. The time, level and message are written under the
+// TimestampFieldName, LevelFieldName and MessageFieldName of the writer.
+type Sink struct {
+	w         kvwriter.KeyValueWriter
+	verbosity int
+	name      string
+	values    []interface{}
+}
+
+var _ logr.LogSink = &Sink{}
+
+// NewSink creates a logr.LogSink writing through w. Info messages with a level above
+// verbosity are dropped.
+func NewSink(w kvwriter.KeyValueWriter, verbosity int) *Sink {
+	return &Sink{w: w, verbosity: verbosity}
+}
+
+// NewLogger creates a logr.Logger writing through w.
+func NewLogger(w kvwriter.KeyValueWriter, verbosity int) logr.Logger {
+	return logr.New(NewSink(w, verbosity))
+}
+
+// Init implements logr.LogSink.
+func (s *Sink) Init(logr.RuntimeInfo) {}
+
+// Enabled reports whether Info messages at the level are written.
+func (s *Sink) Enabled(level int) bool {
+	return level <= s.verbosity
+}
+
+// Info writes a non-error message with the keyvals.
+func (s *Sink) Info(level int, msg string, keyvals ...interface{}) {
+	evt := s.event("info", msg, keyvals)
+	if level > 0 {
+		evt[VerbosityKey] = level
+	}
+	_ = s.w.WriteEvent(evt)
+}
+
+// Error writes an error message with the keyvals.
+func (s *Sink) Error(err error, msg string, keyvals ...interface{}) {
+	evt := s.event("error", msg, keyvals)
+	if err != nil {
+		evt[ErrorKey] = err.Error()
+	}
+	_ = s.w.WriteEvent(evt)
+}
+
+// WithValues returns a sink adding the keyvals to every message.
+func (s *Sink) WithValues(keyvals ...interface{}) logr.LogSink {
+	s2 := *s
+	s2.values = append(s.values[:len(s.values):len(s.values)], keyvals...)
+	return &s2
+}
+
+// WithName returns a sink with the name appended to the logger name.
+func (s *Sink) WithName(name string) logr.LogSink {
+	s2 := *s
+	if s.name == "" {
+		s2.name = name
+	} else {
+		s2.name = s.name + "/" + name
+	}
+	return &s2
+}
+
+func (s *Sink) event(level, msg string, keyvals []interface{}) map[string]interface{} {
+	var evt = make(map[string]interface{}, (len(s.values)+len(keyvals))/2+4)
+	kvwriter.AppendKeyvals(evt, s.values...)
+	kvwriter.AppendKeyvals(evt, keyvals...)
+
+	setField(evt, s.w.TimestampFieldName, time.Now())
+	setField(evt, s.w.LevelFieldName, level)
+	setField(evt, s.w.MessageFieldName, msg)
+	if s.name != "" {
+		evt[NameKey] = s.name
+	}
+	return evt
+}
+
+func setField(evt map[string]interface{}, key string, value interface{}) {
+	if key != "" {
+		evt[key] = value
+	}
+}
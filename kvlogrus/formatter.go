@@ -0,0 +1,81 @@
+// Package kvlogrus implements logrus.Formatter on top of KeyValueWriter, so logrus users
+// get the same ordering, filtering, colors and redaction as JSON events.
+package kvlogrus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// FieldKeyCaller is the key of the caller file and line added by logrus.Logger.ReportCaller.
+const FieldKeyCaller = "caller"
+
+// Formatter formats logrus entries through a KeyValueWriter. The entry time, level,
+// message and caller are written under the TimestampFieldName, LevelFieldName,
+// MessageFieldName and CallerFieldName of the writer. Fields clashing with them are
+// prefixed with "fields.", like logrus.TextFormatter does.
+type Formatter struct {
+	Writer kvwriter.KeyValueWriter
+}
+
+// NewFormatter creates a Formatter using the logrus field names and writing the time,
+// level and message first. The options are applied after these defaults.
+func NewFormatter(options ...kvwriter.Option) *Formatter {
+	var opts = []kvwriter.Option{
+		kvwriter.WithTimestampFieldName(logrus.FieldKeyTime),
+		kvwriter.WithLevelFieldName(logrus.FieldKeyLevel),
+		kvwriter.WithMessageFieldName(logrus.FieldKeyMsg),
+		kvwriter.WithCallerFieldName(FieldKeyCaller),
+		kvwriter.WithFieldsOrder(
+			logrus.FieldKeyTime,
+			logrus.FieldKeyLevel,
+			logrus.FieldKeyMsg,
+			logrus.ErrorKey,
+			FieldKeyCaller,
+			logrus.FieldKeyFunc,
+		),
+	}
+	return &Formatter{Writer: kvwriter.NewKeyValueWriter(append(opts, options...)...)}
+}
+
+// Format renders a single log entry.
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var w = f.Writer
+	var evt = make(map[string]interface{}, len(entry.Data)+5)
+	for k, v := range entry.Data {
+		evt[k] = v
+	}
+
+	var builtin = []string{w.TimestampFieldName, w.LevelFieldName, w.MessageFieldName}
+	if entry.HasCaller() {
+		builtin = append(builtin, w.CallerFieldName, logrus.FieldKeyFunc)
+	}
+	for _, key := range builtin {
+		if v, ok := evt[key]; ok && key != "" {
+			evt["fields."+key] = v
+			delete(evt, key)
+		}
+	}
+
+	if !entry.Time.IsZero() {
+		setField(evt, w.TimestampFieldName, entry.Time.Format(time.RFC3339Nano))
+	}
+	setField(evt, w.LevelFieldName, entry.Level.String())
+	setField(evt, w.MessageFieldName, entry.Message)
+	if entry.HasCaller() {
+		setField(evt, w.CallerFieldName, entry.Caller.File+":"+strconv.Itoa(entry.Caller.Line))
+		evt[logrus.FieldKeyFunc] = entry.Caller.Function
+	}
+
+	return w.FormatEvent(evt)
+}
+
+func setField(evt map[string]interface{}, key string, value interface{}) {
+	if key != "" {
+		evt[key] = value
+	}
+}
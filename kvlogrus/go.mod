@@ -0,0 +1,13 @@
+module github.com/milesich/kv-writer/kvlogrus
+
+go 1.21
+
+require (
+	github.com/milesich/kv-writer v0.1.0
+	github.com/sirupsen/logrus v1.9.3
+)
+
+require (
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+)
@@ -0,0 +1,399 @@
+// Package kvmsgpack decodes MessagePack encoded events for KeyValueWriter, including the
+// message modes of the fluentd forward protocol.
+package kvmsgpack
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Decoder decodes a stream of MessagePack values into events. Maps are decoded as events,
+// arrays as fluentd forward protocol messages ([tag, time, record], [tag, entries] or
+// [tag, packed entries]).
+type Decoder struct {
+	// TagKey is the key the fluentd tag is stored under, or empty to drop it.
+	// (default: "tag")
+	TagKey string
+
+	// TimeKey is the key the fluentd event time is stored under, or empty to drop it.
+	// (default: "time")
+	TimeKey string
+}
+
+var _ kvwriter.Decoder = Decoder{}
+
+// NewDecoder creates a Decoder with the default keys.
+func NewDecoder() Decoder {
+	return Decoder{TagKey: "tag", TimeKey: "time"}
+}
+
+// Decode decodes all values of p into events.
+func (d Decoder) Decode(p []byte) ([]map[string]interface{}, error) {
+	var r = reader{b: p}
+	var events []map[string]interface{}
+	for r.off < len(r.b) {
+		if c := r.b[r.off]; c&0xf0 == 0x90 || c == 0xdc || c == 0xdd {
+			evts, err := d.decodeForward(&r)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, evts...)
+			continue
+		}
+
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		evt, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("msgpack: value of type %T is not an event", v)
+		}
+		events = append(events, evt)
+	}
+	if len(events) == 0 {
+		return nil, errors.New("msgpack: empty input")
+	}
+	return events, nil
+}
+
+// decodeForward decodes a fluentd forward protocol message into its events.
+func (d Decoder) decodeForward(r *reader) ([]map[string]interface{}, error) {
+	n, err := r.arrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 || n > 4 {
+		return nil, fmt.Errorf("msgpack: invalid forward message of %d elements", n)
+	}
+
+	elems := make([]interface{}, n)
+	for i := range elems {
+		if elems[i], err = r.value(); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, ok := elems[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("msgpack: invalid forward message tag of type %T", elems[0])
+	}
+
+	// Message mode: [tag, time, record, option?]
+	if record, ok := elemAt(elems, 2).(map[string]interface{}); ok {
+		return []map[string]interface{}{d.event(tag, elems[1], record)}, nil
+	}
+
+	var entries []interface{}
+	switch e := elems[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option?]
+		entries = e
+	case []byte:
+		// PackedForward mode: [tag, bin(entries), option?]
+		packed := reader{b: e}
+		for packed.off < len(packed.b) {
+			entry, err := packed.value()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return nil, fmt.Errorf("msgpack: invalid forward message entries of type %T", e)
+	}
+
+	var events = make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, errors.New("msgpack: invalid forward message entry")
+		}
+		record, ok := pair[1].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("msgpack: forward message record of type %T is not an event", pair[1])
+		}
+		events = append(events, d.event(tag, pair[0], record))
+	}
+	return events, nil
+}
+
+// event adds the tag and time of a forward message to the record.
+func (d Decoder) event(tag string, t interface{}, record map[string]interface{}) map[string]interface{} {
+	if d.TagKey != "" {
+		record[d.TagKey] = tag
+	}
+	if d.TimeKey != "" {
+		record[d.TimeKey] = t
+	}
+	return record
+}
+
+func elemAt(elems []interface{}, i int) interface{} {
+	if i < len(elems) {
+		return elems[i]
+	}
+	return nil
+}
+
+// reader reads MessagePack values from b. Binary values are returned as []byte and
+// converted by the enclosing map or array.
+type reader struct {
+	b   []byte
+	off int
+}
+
+var errShort = errors.New("msgpack: unexpected end of input")
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.b)-r.off < n {
+		return nil, errShort
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+func (r *reader) uint(n int) (uint64, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// length reads an n byte length, checking that at least min bytes per element remain.
+func (r *reader) length(n, min int) (int, error) {
+	l, err := r.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if l > uint64(len(r.b)-r.off)/uint64(min) {
+		return 0, errShort
+	}
+	return int(l), nil
+}
+
+func (r *reader) arrayLen() (int, error) {
+	c, err := r.uint(1)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xf0 == 0x90:
+		return int(c & 0x0f), nil
+	case c == 0xdc:
+		return r.length(2, 1)
+	case c == 0xdd:
+		return r.length(4, 1)
+	}
+	return 0, fmt.Errorf("msgpack: unexpected type 0x%02x, expected array", c)
+}
+
+func (r *reader) value() (interface{}, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return json.Number(strconv.Itoa(int(c))), nil
+	case c >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(c)))), nil
+	case c&0xf0 == 0x80:
+		return r.readMap(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return r.readArray(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return r.readString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.length(1<<(c-0xc4), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.next(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.length(1<<(c-0xc7), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.readExt(n)
+	case 0xca:
+		u, err := r.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return floatValue(float64(math.Float32frombits(uint32(u))), 32), nil
+	case 0xcb:
+		u, err := r.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return floatValue(math.Float64frombits(u), 64), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := r.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		u, err := r.uint(n)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend the n byte value.
+		s := int64(u<<(64-8*n)) >> (64 - 8*n)
+		return json.Number(strconv.FormatInt(s, 10)), nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.readExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.length(1<<(c-0xd9), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.readString(n)
+	case 0xdc, 0xdd:
+		n, err := r.length(2<<(c-0xdc), 1)
+		if err != nil {
+			return nil, err
+		}
+		return r.readArray(n)
+	case 0xde, 0xdf:
+		n, err := r.length(2<<(c-0xde), 2)
+		if err != nil {
+			return nil, err
+		}
+		return r.readMap(n)
+	}
+	return nil, fmt.Errorf("msgpack: invalid type 0x%02x at offset %d", c, r.off-1)
+}
+
+func (r *reader) readString(n int) (interface{}, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *reader) readArray(n int) (interface{}, error) {
+	var arr = make([]interface{}, n)
+	for i := range arr {
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = binValue(v)
+	}
+	return arr, nil
+}
+
+func (r *reader) readMap(n int) (interface{}, error) {
+	var m = make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		m[keyString(k)] = binValue(v)
+	}
+	return m, nil
+}
+
+// readExt reads an extension of n data bytes. Timestamps (type -1) and fluentd event times
+// (type 0) are returned as RFC 3339 strings, other extensions as base64 strings.
+func (r *reader) readExt(n int) (interface{}, error) {
+	t, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var sec, nsec int64
+	switch {
+	case int8(t[0]) == -1 && n == 4:
+		sec = int64(binary.BigEndian.Uint32(b))
+	case int8(t[0]) == -1 && n == 8:
+		u := binary.BigEndian.Uint64(b)
+		sec, nsec = int64(u&(1<<34-1)), int64(u>>34)
+	case int8(t[0]) == -1 && n == 12:
+		sec, nsec = int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b))
+	case t[0] == 0 && n == 8:
+		sec, nsec = int64(binary.BigEndian.Uint32(b)), int64(binary.BigEndian.Uint32(b[4:]))
+	default:
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	return time.Unix(sec, nsec).UTC().Format(time.RFC3339Nano), nil
+}
+
+// floatValue converts f to a json.Number, or a string if it is not a valid JSON number.
+func floatValue(f float64, bits int) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+}
+
+// binValue converts binary values to strings, base64 encoded unless valid UTF-8.
+func binValue(v interface{}) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// keyString converts a map key to a string.
+func keyString(k interface{}) string {
+	switch k := binValue(k).(type) {
+	case string:
+		return k
+	case json.Number:
+		return k.String()
+	case nil:
+		return "null"
+	default:
+		b, _ := json.Marshal(k)
+		return string(b)
+	}
+}
@@ -0,0 +1,96 @@
+package kvmsgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// str encodes s as a MessagePack fixstr.
+func str(s string) []byte {
+	return append([]byte{0xa0 | byte(len(s))}, s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestDecode(t *testing.T) {
+	// 1700000000 as uint32
+	epoch := []byte{0x65, 0x53, 0xf1, 0x00}
+	entry := func(n byte) []byte {
+		return concat([]byte{0x92, n, 0x81}, str("a"), []byte{n})
+	}
+	entries := concat(entry(1), entry(2))
+
+	tests := []struct {
+		name string
+		in   []byte
+		want []map[string]interface{}
+	}{
+		{"map", concat([]byte{0x86},
+			str("msg"), str("hi"),
+			str("n"), []byte{0xff},
+			str("ok"), []byte{0xc3},
+			str("f"), []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+			str("none"), []byte{0xc0},
+			str("bin"), []byte{0xc4, 0x01, 0xff},
+		), []map[string]interface{}{{
+			"msg": "hi", "n": json.Number("-1"), "ok": true, "f": json.Number("1.5"), "none": nil, "bin": "/w==",
+		}}},
+		{"timestamp", concat([]byte{0x81}, str("time"), []byte{0xd6, 0xff}, epoch),
+			[]map[string]interface{}{{"time": "2023-11-14T22:13:20Z"}}},
+		{"stream", concat([]byte{0x81}, str("a"), []byte{0x01, 0x81}, str("a"), []byte{0x02}),
+			[]map[string]interface{}{{"a": json.Number("1")}, {"a": json.Number("2")}}},
+		{"message mode", concat([]byte{0x93}, str("app"), []byte{0xce}, epoch, []byte{0x81}, str("a"), []byte{0x01}),
+			[]map[string]interface{}{{"a": json.Number("1"), "tag": "app", "time": json.Number("1700000000")}}},
+		{"forward mode", concat([]byte{0x92}, str("app"), []byte{0x92}, entries),
+			[]map[string]interface{}{
+				{"a": json.Number("1"), "tag": "app", "time": json.Number("1")},
+				{"a": json.Number("2"), "tag": "app", "time": json.Number("2")},
+			}},
+		{"packed forward mode", concat([]byte{0x92}, str("app"), []byte{0xc4, byte(len(entries))}, entries),
+			[]map[string]interface{}{
+				{"a": json.Number("1"), "tag": "app", "time": json.Number("1")},
+				{"a": json.Number("2"), "tag": "app", "time": json.Number("2")},
+			}},
+	}
+	for _, tt := range tests {
+		got, err := NewDecoder().Decode(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":     nil,
+		"truncated": concat([]byte{0x81}, str("a")),
+		"scalar":    {0x01},
+		"invalid":   {0xc1},
+		"forward":   concat([]byte{0x92}, []byte{0x01, 0x02}),
+	} {
+		if _, err := NewDecoder().Decode(in); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := kvwriter.NewKeyValueWriter(kvwriter.WithOutput(&out), kvwriter.WithDecoder(Decoder{}))
+	if _, err := w.Write(concat([]byte{0x82}, str("msg"), str("hi"), str("http"), []byte{0x81}, str("code"), []byte{0xcc, 0xc8})); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "http.code=\"200\" msg=\"hi\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
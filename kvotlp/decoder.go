@@ -0,0 +1,338 @@
+// Package kvotlp converts OpenTelemetry log records into events for KeyValueWriter, either
+// decoded from the OTLP/JSON encoding written by the collector's file exporter or built
+// from Record values, e.g. in an exporter of the Go SDK.
+package kvotlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Decoder decodes OTLP/JSON log exports into one event per log record.
+type Decoder struct {
+	// TimestampKey is the key of the record time, or of the observed time if not set.
+	// (default: "time")
+	TimestampKey string
+
+	// LevelKey is the key of the level derived from the severity. (default: "level")
+	LevelKey string
+
+	// MessageKey is the key of the record body. Bodies that are maps are flattened by the
+	// writer like any other object. (default: "message")
+	MessageKey string
+
+	// TraceIDKey and SpanIDKey are the keys of the hex encoded trace and span IDs, or empty
+	// to drop them. (default: "trace_id" and "span_id")
+	TraceIDKey string
+	SpanIDKey  string
+
+	// ScopeKey is the key of the instrumentation scope name, or empty to drop it.
+	// (default: "scope")
+	ScopeKey string
+
+	// ResourcePrefix is prepended to the keys of resource attributes, or empty to drop
+	// them. (default: "resource.")
+	ResourcePrefix string
+}
+
+var _ kvwriter.Decoder = Decoder{}
+
+// NewDecoder creates a Decoder with the default keys, which match the default field names
+// of KeyValueWriter.
+func NewDecoder() Decoder {
+	return Decoder{
+		TimestampKey:   "time",
+		LevelKey:       "level",
+		MessageKey:     "message",
+		TraceIDKey:     "trace_id",
+		SpanIDKey:      "span_id",
+		ScopeKey:       "scope",
+		ResourcePrefix: "resource.",
+	}
+}
+
+// Record is a log record of the OpenTelemetry data model. Values of Body, Attributes
+// and Resource may have any type accepted by KeyValueWriter.WriteEvent.
+type Record struct {
+	Timestamp         time.Time
+	ObservedTimestamp time.Time
+	SeverityNumber    int
+	SeverityText      string
+	Body              interface{}
+	Attributes        map[string]interface{}
+	TraceID           [16]byte
+	SpanID            [8]byte
+	EventName         string
+
+	// Scope is the name of the instrumentation scope.
+	Scope    string
+	Resource map[string]interface{}
+}
+
+// Event converts the record into an event, to be written with KeyValueWriter.WriteEvent.
+// Attributes replace resource attributes and are replaced by the fields of the record.
+func (d Decoder) Event(r Record) map[string]interface{} {
+	var evt = make(map[string]interface{}, len(r.Attributes)+len(r.Resource)+6)
+	if d.ResourcePrefix != "" {
+		for k, v := range r.Resource {
+			evt[d.ResourcePrefix+k] = v
+		}
+	}
+	for k, v := range r.Attributes {
+		evt[k] = v
+	}
+
+	var ts = r.Timestamp
+	if ts.IsZero() {
+		ts = r.ObservedTimestamp
+	}
+	if !ts.IsZero() {
+		evt[d.TimestampKey] = ts.UTC().Format(time.RFC3339Nano)
+	}
+	if level := severityLevel(r.SeverityNumber, r.SeverityText); level != "" {
+		evt[d.LevelKey] = level
+	}
+	if r.Body != nil {
+		evt[d.MessageKey] = r.Body
+	}
+	if r.EventName != "" {
+		evt["event.name"] = r.EventName
+	}
+	if d.ScopeKey != "" && r.Scope != "" {
+		evt[d.ScopeKey] = r.Scope
+	}
+	if d.TraceIDKey != "" && r.TraceID != [16]byte{} {
+		evt[d.TraceIDKey] = hex.EncodeToString(r.TraceID[:])
+	}
+	if d.SpanIDKey != "" && r.SpanID != [8]byte{} {
+		evt[d.SpanIDKey] = hex.EncodeToString(r.SpanID[:])
+	}
+	return evt
+}
+
+// severityLevel returns the level name of the severity text if the writer knows it, and
+// of the range of the severity number otherwise.
+func severityLevel(number int, text string) string {
+	if _, ok := kvwriter.ParseLevel(text); ok {
+		return text
+	}
+	switch {
+	case number >= 1 && number <= 4:
+		return "trace"
+	case number >= 5 && number <= 8:
+		return "debug"
+	case number >= 9 && number <= 12:
+		return "info"
+	case number >= 13 && number <= 16:
+		return "warn"
+	case number >= 17 && number <= 20:
+		return "error"
+	case number >= 21 && number <= 24:
+		return "fatal"
+	}
+	return text
+}
+
+// Decode decodes all export requests of p, usually one per line, into events.
+func (d Decoder) Decode(p []byte) ([]map[string]interface{}, error) {
+	var events []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	for {
+		var req exportRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("otlp: %w", err)
+		}
+
+		for _, rl := range req.ResourceLogs {
+			resource := attributesMap(rl.Resource.Attributes)
+			for _, sl := range rl.ScopeLogs {
+				for _, lr := range sl.LogRecords {
+					r, err := lr.record()
+					if err != nil {
+						return nil, fmt.Errorf("otlp: %w", err)
+					}
+					r.Scope = sl.Scope.Name
+					r.Resource = resource
+					events = append(events, d.Event(r))
+				}
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, errors.New("otlp: no log records")
+	}
+	return events, nil
+}
+
+// exportRequest and the types below are the parts of the OTLP/JSON encoding of log
+// exports used by Decode.
+type exportRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []keyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []logRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type logRecord struct {
+	TimeUnixNano         number     `json:"timeUnixNano"`
+	ObservedTimeUnixNano number     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 *anyValue  `json:"body"`
+	Attributes           []keyValue `json:"attributes"`
+	TraceID              string     `json:"traceId"`
+	SpanID               string     `json:"spanId"`
+	EventName            string     `json:"eventName"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue"`
+	BoolValue   *bool   `json:"boolValue"`
+	IntValue    *number `json:"intValue"`
+	DoubleValue *number `json:"doubleValue"`
+	BytesValue  *string `json:"bytesValue"`
+	ArrayValue  *struct {
+		Values []anyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []keyValue `json:"values"`
+	} `json:"kvlistValue"`
+}
+
+// number is an integer or float encoded as a JSON number or string, as OTLP/JSON does for
+// 64-bit integers. Special floats like "NaN" are kept as strings.
+type number string
+
+func (n *number) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*n = number(s)
+		return nil
+	}
+	*n = number(b)
+	return nil
+}
+
+// value returns the number as json.Number, or as string if it is not a valid number.
+func (n number) value() interface{} {
+	if _, err := strconv.ParseFloat(string(n), 64); err == nil && json.Valid([]byte(n)) {
+		return json.Number(n)
+	}
+	return string(n)
+}
+
+// time converts nanoseconds since the Unix epoch to time, or zero if not set.
+func (n number) time() (time.Time, error) {
+	if n == "" || n == "0" {
+		return time.Time{}, nil
+	}
+	ns, err := strconv.ParseInt(string(n), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", string(n))
+	}
+	return time.Unix(0, ns), nil
+}
+
+func (lr logRecord) record() (Record, error) {
+	var r = Record{
+		SeverityNumber: lr.SeverityNumber,
+		SeverityText:   lr.SeverityText,
+		Attributes:     attributesMap(lr.Attributes),
+		EventName:      lr.EventName,
+	}
+	var err error
+	if r.Timestamp, err = lr.TimeUnixNano.time(); err != nil {
+		return Record{}, err
+	}
+	if r.ObservedTimestamp, err = lr.ObservedTimeUnixNano.time(); err != nil {
+		return Record{}, err
+	}
+	if lr.Body != nil {
+		r.Body = lr.Body.value()
+	}
+	if err := decodeID(r.TraceID[:], lr.TraceID); err != nil {
+		return Record{}, fmt.Errorf("invalid trace ID: %w", err)
+	}
+	if err := decodeID(r.SpanID[:], lr.SpanID); err != nil {
+		return Record{}, fmt.Errorf("invalid span ID: %w", err)
+	}
+	return r, nil
+}
+
+// decodeID decodes a hex encoded ID into dst, which is left zero if s is empty.
+func decodeID(dst []byte, s string) error {
+	if s == "" {
+		return nil
+	}
+	if hex.DecodedLen(len(s)) != len(dst) {
+		return fmt.Errorf("%q is not %d bytes", s, len(dst))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func attributesMap(kvs []keyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	var m = make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.value()
+	}
+	return m
+}
+
+// value converts the value to the types produced by decoding JSON. Bytes are written
+// base64 encoded, as in the input.
+func (v anyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return v.IntValue.value()
+	case v.DoubleValue != nil:
+		return v.DoubleValue.value()
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		var arr = make([]interface{}, len(v.ArrayValue.Values))
+		for i, elem := range v.ArrayValue.Values {
+			arr[i] = elem.value()
+		}
+		return arr
+	case v.KvlistValue != nil:
+		if m := attributesMap(v.KvlistValue.Values); m != nil {
+			return m
+		}
+		return map[string]interface{}{}
+	}
+	return nil
+}
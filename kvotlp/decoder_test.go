@@ -0,0 +1,106 @@
+package kvotlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+const export = `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]},` +
+	`"scopeLogs":[{"scope":{"name":"http"},"logRecords":[{"timeUnixNano":"1700000000500000000","severityNumber":17,` +
+	`"body":{"stringValue":"failed"},"attributes":[{"key":"code","value":{"intValue":"500"}},` +
+	`{"key":"ratio","value":{"doubleValue":0.5}},{"key":"ok","value":{"boolValue":false}},` +
+	`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"}]}}},` +
+	`{"key":"req","value":{"kvlistValue":{"values":[{"key":"id","value":{"intValue":7}}]}}}],` +
+	`"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174"},` +
+	`{"observedTimeUnixNano":"1700000000000000000","severityText":"NOTICE","severityNumber":10}]}]}]}`
+
+func TestDecode(t *testing.T) {
+	got, err := NewDecoder().Decode([]byte(export + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{{
+		"resource.service.name": "api",
+		"scope":                 "http",
+		"time":                  "2023-11-14T22:13:20.5Z",
+		"level":                 "error",
+		"message":               "failed",
+		"code":                  json.Number("500"),
+		"ratio":                 json.Number("0.5"),
+		"ok":                    false,
+		"tags":                  []interface{}{"a"},
+		"req":                   map[string]interface{}{"id": json.Number("7")},
+		"trace_id":              "5b8efff798038103d269b633813fc60c",
+		"span_id":               "eee19b7ec3c1b174",
+	}, {
+		"resource.service.name": "api",
+		"scope":                 "http",
+		"time":                  "2023-11-14T22:13:20Z",
+		"level":                 "NOTICE",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"invalid JSON": `{"resourceLogs":`,
+		"no records":   `{"resourceLogs":[]}`,
+		"trace ID":     `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"traceId":"abc"}]}]}]}`,
+		"time":         `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"timeUnixNano":"soon"}]}]}]}`,
+	} {
+		if _, err := NewDecoder().Decode([]byte(in)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestSeverityLevel(t *testing.T) {
+	tests := []struct {
+		number int
+		text   string
+		want   string
+	}{
+		{1, "", "trace"},
+		{9, "", "info"},
+		{13, "", "warn"},
+		{24, "", "fatal"},
+		{9, "debug", "debug"},
+		{0, "custom", "custom"},
+		{0, "", ""},
+	}
+	for _, tt := range tests {
+		if got := severityLevel(tt.number, tt.text); got != tt.want {
+			t.Errorf("severityLevel(%d, %q) = %q, want %q", tt.number, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEvent(t *testing.T) {
+	d := NewDecoder()
+	d.ResourcePrefix, d.ScopeKey = "", ""
+	evt := d.Event(Record{
+		Timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Body:       "hi",
+		Attributes: map[string]interface{}{"message": "replaced"},
+		Scope:      "dropped",
+		Resource:   map[string]interface{}{"host": "dropped"},
+		EventName:  "login",
+		SpanID:     [8]byte{1},
+	})
+
+	var out bytes.Buffer
+	w := kvwriter.NewKeyValueWriter(kvwriter.WithOutput(&out))
+	if err := w.WriteEvent(evt); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "event.name=\"login\" message=\"hi\" span_id=\"0100000000000000\" time=\"2024-01-02T03:04:05Z\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
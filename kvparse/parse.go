@@ -0,0 +1,222 @@
+// Package kvparse parses lines written by KeyValueWriter back into maps, e.g. for
+// round-trip tests and downstream tooling.
+package kvparse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Parser parses single lines of key-value pairs.
+type Parser struct {
+	// PairsDelimiter delimits individual pairs. (default: " ")
+	PairsDelimiter string
+
+	// KeyValueDelimiter delimits key and value. (default: "=")
+	KeyValueDelimiter string
+
+	// QuoteChar quotes keys and values: a double quote, a single quote or a backtick.
+	// (default: '"')
+	QuoteChar byte
+}
+
+// NewParser creates a Parser for the lines written by w.
+func NewParser(w kvwriter.KeyValueWriter) Parser {
+	p := Parser{
+		PairsDelimiter:    w.PairsSeparator,
+		KeyValueDelimiter: w.KeyValueSeparator,
+	}
+	if w.QuoteChar != 0 && !w.LogfmtMode {
+		p.QuoteChar = byte(w.QuoteChar)
+	}
+	if p.PairsDelimiter == "" && w.PairsDelimiter != 0 {
+		p.PairsDelimiter = string(w.PairsDelimiter)
+	}
+	if p.KeyValueDelimiter == "" && w.KeyValueDelimiter != 0 {
+		p.KeyValueDelimiter = string(w.KeyValueDelimiter)
+	}
+	return p
+}
+
+// ParseStrings parses the line into a map of unquoted values.
+func (p Parser) ParseStrings(line string) (map[string]string, error) {
+	var out = make(map[string]string)
+	err := p.parse(line, func(key, value string, quoted bool) {
+		out[key] = value
+	})
+	return out, err
+}
+
+// Parse parses the line into an event. Quoted values are strings, unquoted numbers become
+// json.Number, unquoted true and false booleans and unquoted null nil.
+func (p Parser) Parse(line string) (map[string]interface{}, error) {
+	var out = make(map[string]interface{})
+	err := p.parse(line, func(key, value string, quoted bool) {
+		if quoted {
+			out[key] = value
+		} else {
+			out[key] = scalar(value)
+		}
+	})
+	return out, err
+}
+
+func (p Parser) parse(line string, add func(key, value string, quoted bool)) error {
+	var pd, kvd = p.PairsDelimiter, p.KeyValueDelimiter
+	if pd == "" {
+		pd = " "
+	}
+	if kvd == "" {
+		kvd = "="
+	}
+	if pd == kvd {
+		return errors.New("pairs and key-value delimiters are equal")
+	}
+	var q = p.QuoteChar
+	if q == 0 {
+		q = '"'
+	}
+
+	// Padding written by AlignValues precedes the pairs delimiter.
+	var trimmedPd = strings.TrimLeft(pd, " ")
+
+	var s = StripANSI(strings.TrimRight(line, "\r\n"))
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return nil
+		}
+
+		var key string
+		if s[0] == q {
+			k, rest, err := unquote(s, q)
+			if err != nil {
+				return fmt.Errorf("invalid key: %s", err)
+			}
+			key, s = k, rest
+			if !strings.HasPrefix(s, kvd) {
+				return fmt.Errorf("missing delimiter after key %q", key)
+			}
+		} else {
+			i := strings.Index(s, kvd)
+			if i < 0 {
+				return fmt.Errorf("missing delimiter after key %q", s)
+			}
+			key, s = s[:i], s[i:]
+		}
+		s = s[len(kvd):]
+
+		if s != "" && s[0] == q {
+			value, rest, err := unquote(s, q)
+			if err != nil {
+				return fmt.Errorf("invalid value of %q: %s", key, err)
+			}
+			add(key, value, true)
+			s = rest
+		} else {
+			i := strings.Index(s, pd)
+			if i < 0 {
+				i = len(s)
+			}
+			add(key, strings.TrimRight(s[:i], " "), false)
+			s = s[i:]
+		}
+
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return nil
+		}
+		if trimmedPd != "" {
+			if !strings.HasPrefix(s, trimmedPd) {
+				return fmt.Errorf("missing delimiter after value of %q", key)
+			}
+			s = s[len(trimmedPd):]
+		}
+	}
+}
+
+// unquote unquotes the string quoted by q at the start of s and returns it with the rest
+// of s.
+func unquote(s string, q byte) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case q:
+			v, err := strconv.Unquote(goQuoted(s[:i+1], q))
+			if err != nil {
+				return "", "", err
+			}
+			return v, s[i+1:], nil
+		}
+	}
+	return "", "", errors.New("unterminated quoted string")
+}
+
+// goQuoted converts the string s quoted by q into a Go string literal.
+func goQuoted(s string, q byte) string {
+	if q == '"' {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteByte('"')
+	for i := 1; i < len(s)-1; i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s)-1 && s[i+1] == q:
+			b.WriteByte(q)
+			i++
+		case c == '\\' && i+1 < len(s)-1:
+			b.WriteByte(c)
+			b.WriteByte(s[i+1])
+			i++
+		case c == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func scalar(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) {
+		return json.Number(s)
+	}
+	return s
+}
+
+// StripANSI removes ANSI escape sequences written by colorized output.
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b[") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '[' {
+			i += 2
+			for i < len(s) && (s[i] < 0x40 || s[i] > 0x7e) {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
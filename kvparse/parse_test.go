@@ -0,0 +1,78 @@
+package kvparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+func TestRoundTrip(t *testing.T) {
+	const in = `{"msg":"say \"hi\"\tthere","n":-1.5e3,"ok":true,"none":null,"http":{"path":"/a b"},"empty":""}`
+	want := map[string]interface{}{
+		"msg":       "say \"hi\"\tthere",
+		"n":         json.Number("-1.5e3"),
+		"ok":        true,
+		"none":      nil,
+		"http.path": "/a b",
+		"empty":     "",
+	}
+
+	tests := []struct {
+		name    string
+		options []kvwriter.Option
+	}{
+		{"default", nil},
+		{"separators", []kvwriter.Option{kvwriter.WithPairsSeparator(" | "), kvwriter.WithKeyValueSeparator(": ")}},
+		{"single quotes", []kvwriter.Option{kvwriter.WithQuoteChar('\'')}},
+		{"backticks", []kvwriter.Option{kvwriter.WithQuoteChar('`')}},
+		{"colors", []kvwriter.Option{kvwriter.WithColorize(kvwriter.ColorAlways)}},
+		{"aligned", []kvwriter.Option{kvwriter.WithAlignValues(true, 0)}},
+		{"quote auto", []kvwriter.Option{kvwriter.WithQuoteMode(kvwriter.QuoteAuto)}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := kvwriter.NewKeyValueWriter(append(tt.options, kvwriter.WithOutput(&out), kvwriter.WithPreserveTypes(true))...)
+		if _, err := w.Write([]byte(in)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		got, err := NewParser(w).Parse(out.String())
+		if err != nil {
+			t.Errorf("%s: parse %q: %v", tt.name, out.String(), err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: parsed %q into %v, want %v", tt.name, out.String(), got, want)
+		}
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	got, err := Parser{}.ParseStrings(`level="info" n=5 msg="a=b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"level": "info", "n": "5", "msg": "a=b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{`msg="unterminated`, `key`, `a="1" b`} {
+		if _, err := (Parser{}).Parse(line); err == nil {
+			t.Errorf("no error for %q", line)
+		}
+	}
+	if _, err := (Parser{PairsDelimiter: "=", KeyValueDelimiter: "="}).Parse("a=1"); err == nil {
+		t.Error("no error for equal delimiters")
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	if got := StripANSI("\x1b[1;31mERR\x1b[0m ok"); got != "ERR ok" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,296 @@
+// Package kvrotate provides a file writer with size and time based rotation, retention of
+// a maximum number of backups and optional compression, to use KeyValueWriter as a file
+// logger.
+package kvrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp format of the names of rotated files.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressSuffix is appended to the names of compressed rotated files.
+const compressSuffix = ".gz"
+
+// File is an io.WriteCloser writing to Filename and rotating it. Rotated files are renamed
+// to the name of the file with the time of the rotation inserted before the extension,
+// e.g. app-2024-06-10T15-05-22.000.log, followed by a counter if the file was rotated
+// several times within a millisecond, e.g. app-2024-06-10T15-05-22.000_2.log. The file is
+// opened on the first write, writes after Close fail with os.ErrClosed.
+type File struct {
+	// Filename is the file to write. Missing directories are created.
+	Filename string
+
+	// MaxSize rotates the file before a write makes it exceed MaxSize bytes.
+	// (default: 0, disabled)
+	MaxSize int64
+
+	// Interval rotates the file once Interval passed since it was opened.
+	// (default: 0, disabled)
+	Interval time.Duration
+
+	// MaxBackups deletes the oldest rotated files beyond MaxBackups. (default: 0, keeps
+	// all files)
+	MaxBackups int
+
+	// Compress compresses rotated files with gzip. (default: false)
+	Compress bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	millMu sync.Mutex
+	mills  sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*File)(nil)
+
+// NewFile creates a File writing to filename, rotating it at maxSize bytes and keeping
+// maxBackups rotated files.
+func NewFile(filename string, maxSize int64, maxBackups int) *File {
+	return &File{Filename: filename, MaxSize: maxSize, MaxBackups: maxBackups}
+}
+
+// Write writes p to the file, rotating it first if needed.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	var full = f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize
+	var expired = f.Interval > 0 && time.Since(f.opened) >= f.Interval
+	if full || expired {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the file, renames it and opens a new one.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the file and waits until the rotated files are compressed and removed.
+func (f *File) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.mills.Wait()
+	return err
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Filename), 0o755); err != nil {
+		return fmt.Errorf("kvrotate: %s", err)
+	}
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("kvrotate: %s", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("kvrotate: %s", err)
+	}
+
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+func (f *File) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("kvrotate: %s", err)
+		}
+		f.file = nil
+	}
+
+	if _, err := os.Stat(f.Filename); err == nil {
+		if err := os.Rename(f.Filename, f.freeBackupName(time.Now())); err != nil {
+			return fmt.Errorf("kvrotate: %s", err)
+		}
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.mills.Add(1)
+	go f.mill()
+	return nil
+}
+
+// backupName returns the name of the file rotated at t, with the counter n if above 1.
+func (f *File) backupName(t time.Time, n int) string {
+	ext := filepath.Ext(f.Filename)
+	stamp := t.Format(backupTimeFormat)
+	if n > 1 {
+		stamp += "_" + strconv.Itoa(n)
+	}
+	return strings.TrimSuffix(f.Filename, ext) + "-" + stamp + ext
+}
+
+// freeBackupName returns the first name of the file rotated at t that is not used by an
+// uncompressed or compressed backup.
+func (f *File) freeBackupName(t time.Time) string {
+	for n := 1; ; n++ {
+		name := f.backupName(t, n)
+		if !exists(name) && !exists(name+compressSuffix) {
+			return name
+		}
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// mill compresses and removes the rotated files.
+func (f *File) mill() {
+	defer f.mills.Done()
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		return
+	}
+
+	if f.MaxBackups > 0 && len(backups) > f.MaxBackups {
+		for _, b := range backups[:len(backups)-f.MaxBackups] {
+			_ = os.Remove(b)
+		}
+		backups = backups[len(backups)-f.MaxBackups:]
+	}
+
+	if f.Compress {
+		for _, b := range backups {
+			if !strings.HasSuffix(b, compressSuffix) {
+				_ = compressFile(b)
+			}
+		}
+	}
+}
+
+// backups returns the rotated files ordered from the oldest.
+func (f *File) backups() ([]string, error) {
+	ext := filepath.Ext(f.Filename)
+	prefix := strings.TrimSuffix(f.Filename, ext) + "-"
+
+	matches, err := filepath.Glob(globEscape(prefix) + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name string
+		t    time.Time
+		n    int
+	}
+	var found []backup
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(m, compressSuffix), ext)
+		stamp = strings.TrimPrefix(stamp, prefix)
+		var n = 1
+		if i := strings.LastIndexByte(stamp, '_'); i >= 0 {
+			c, err := strconv.Atoi(stamp[i+1:])
+			if err != nil || c < 2 {
+				continue
+			}
+			stamp, n = stamp[:i], c
+		}
+		if t, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			found = append(found, backup{m, t, n})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].t.Equal(found[j].t) {
+			return found[i].t.Before(found[j].t)
+		}
+		return found[i].n < found[j].n
+	})
+
+	var backups = make([]string, len(found))
+	for i, b := range found {
+		backups[i] = b.name
+	}
+	return backups, nil
+}
+
+// compressFile replaces name with its gzip compressed copy.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(name + compressSuffix)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(name + compressSuffix)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// globEscape escapes the meta characters of filepath.Match in s.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[\`, c) && (c != '\\' || filepath.Separator != '\\') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
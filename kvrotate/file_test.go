@@ -0,0 +1,146 @@
+package kvrotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotateWithinMillisecond(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "app.log"), 0, 0)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(f.freeBackupName(now), []byte{byte('a' + i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("got backups %v", backups)
+	}
+	for i, b := range backups {
+		if data, _ := os.ReadFile(b); string(data) != string(rune('a'+i)) {
+			t.Errorf("backup %s contains %q", b, data)
+		}
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "app.log"), 0, 0)
+	if _, err := f.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("b\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("write after close: %v", err)
+	}
+	if err := f.Rotate(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("rotate after close: %v", err)
+	}
+	if data, _ := os.ReadFile(f.Filename); string(data) != "a\n" {
+		t.Errorf("file contains %q", data)
+	}
+}
+
+func TestRotateSize(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "logs", "app.log"), 10, 0)
+	for _, line := range []string{"12345\n", "1234\n", "abc\n", "de\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got backups %v", backups)
+	}
+	for i, want := range []string{"12345\n", "1234\nabc\n"} {
+		if data, _ := os.ReadFile(backups[i]); string(data) != want {
+			t.Errorf("backup %s contains %q, want %q", backups[i], data, want)
+		}
+	}
+	if data, _ := os.ReadFile(f.Filename); string(data) != "de\n" {
+		t.Errorf("file contains %q", data)
+	}
+}
+
+func TestRotateInterval(t *testing.T) {
+	f := &File{Filename: filepath.Join(t.TempDir(), "app.log"), Interval: time.Millisecond}
+	defer f.Close()
+	if _, err := f.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := f.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(f.Filename); string(data) != "b\n" {
+		t.Errorf("file contains %q", data)
+	}
+}
+
+func TestRetentionAndCompression(t *testing.T) {
+	f := &File{Filename: filepath.Join(t.TempDir(), "app.log"), MaxBackups: 2, Compress: true}
+	for i := 0; i < 4; i++ {
+		if _, err := f.Write([]byte{byte('a' + i), '\n'}); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got backups %v", backups)
+	}
+	for i, b := range backups {
+		if filepath.Ext(b) != compressSuffix {
+			t.Errorf("backup %s is not compressed", b)
+			continue
+		}
+		if got, want := gunzip(t, b), string([]byte{byte('c' + i), '\n'}); got != want {
+			t.Errorf("backup %s contains %q, want %q", b, got, want)
+		}
+	}
+}
+
+func gunzip(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,225 @@
+// Package kvsyslog writes events formatted by KeyValueWriter to a local or remote syslog
+// daemon as RFC 5424 messages, mapping their level to the syslog severity.
+package kvsyslog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// Facility is the syslog facility of the messages.
+type Facility int
+
+// Syslog facilities.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// Severity is the syslog severity of a message.
+type Severity int
+
+// Syslog severities.
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+// localSockets are the sockets of the local syslog daemon tried by Dial.
+var localSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Writer writes every event as a syslog message. It is safe for concurrent use.
+type Writer struct {
+	// Writer formats the events.
+	Writer kvwriter.KeyValueWriter
+
+	// Facility is the facility of the messages. (default: FacilityUser)
+	Facility Facility
+
+	// AppNameField is the key of the field holding the app name. (default: "app")
+	AppNameField string
+
+	// AppName is used for events without AppNameField. (default: the program name)
+	AppName string
+
+	// Hostname is the host name of the messages. (default: os.Hostname)
+	Hostname string
+
+	network string
+	mu      sync.Mutex
+	conn    net.Conn
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// Dial connects to the syslog daemon at raddr over network, e.g. "udp" or "tcp". If
+// network is empty, it connects to the local syslog daemon.
+func Dial(network, raddr string, w kvwriter.KeyValueWriter) (*Writer, error) {
+	hostname, _ := os.Hostname()
+	s := &Writer{
+		Writer:       w,
+		Facility:     FacilityUser,
+		AppNameField: "app",
+		AppName:      filepath.Base(os.Args[0]),
+		Hostname:     hostname,
+	}
+
+	var err error
+	if network == "" {
+		for _, path := range localSockets {
+			for _, n := range []string{"unixgram", "unix"} {
+				if s.conn, err = net.Dial(n, path); err == nil {
+					s.network = n
+					return s, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("kvsyslog: cannot connect to local syslog: %s", err)
+	}
+
+	if s.conn, err = net.Dial(network, raddr); err != nil {
+		return nil, fmt.Errorf("kvsyslog: %s", err)
+	}
+	s.network = network
+	return s, nil
+}
+
+// Write decodes the events of p like KeyValueWriter.Write and writes each as a syslog
+// message. Output for invalid input, e.g. with ErrorPassThrough, is written with
+// SeverityInfo.
+func (s *Writer) Write(p []byte) (int, error) {
+	return s.Writer.DecodeFunc(p, func(evt map[string]interface{}, raw []byte) error {
+		if evt == nil {
+			return s.send(s.message(SeverityInfo, s.AppName, bytes.TrimRight(raw, "\n"), time.Now()))
+		}
+		return s.WriteEvent(evt)
+	})
+}
+
+// WriteEvent formats the event and writes it as a syslog message.
+func (s *Writer) WriteEvent(evt map[string]interface{}) error {
+	severity := LevelSeverity(s.Writer.EventLevel(evt))
+	appName := s.AppName
+	if v, ok := evt[s.AppNameField].(string); ok && v != "" {
+		appName = v
+	}
+
+	line, err := s.Writer.FormatEvent(evt)
+	if err != nil || line == nil {
+		return err
+	}
+	return s.send(s.message(severity, appName, bytes.TrimRight(line, "\n"), time.Now()))
+}
+
+// Close closes the connection.
+func (s *Writer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.Close()
+}
+
+// message formats an RFC 5424 message.
+func (s *Writer) message(severity Severity, appName string, msg []byte, t time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(int(s.Facility)*8 + int(severity)))
+	b.WriteString(">1 ")
+	b.WriteString(t.Format("2006-01-02T15:04:05.000000Z07:00"))
+	b.WriteByte(' ')
+	b.WriteString(header(s.Hostname, 255))
+	b.WriteByte(' ')
+	b.WriteString(header(appName, 48))
+	b.WriteByte(' ')
+	b.WriteString(strconv.Itoa(os.Getpid()))
+	b.WriteString(" - - ")
+	b.Write(msg)
+	return b.Bytes()
+}
+
+// send writes the message, framed by octet counting on stream connections.
+func (s *Writer) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == "tcp" || s.network == "tcp4" || s.network == "tcp6" || s.network == "unix" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	if _, err := s.conn.Write(msg); err != nil {
+		return &kvwriter.SinkError{Output: msg, Err: err}
+	}
+	return nil
+}
+
+// header returns s as a header field of at most n printable US-ASCII characters, or "-"
+// if empty.
+func header(s string, n int) string {
+	var b = make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < n; i++ {
+		if s[i] > ' ' && s[i] < 0x7f {
+			b = append(b, s[i])
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// LevelSeverity maps a level to its syslog severity. Events without a known level are
+// informational.
+func LevelSeverity(l kvwriter.Level) Severity {
+	switch l {
+	case kvwriter.LevelTrace, kvwriter.LevelDebug:
+		return SeverityDebug
+	case kvwriter.LevelNotice:
+		return SeverityNotice
+	case kvwriter.LevelWarn:
+		return SeverityWarning
+	case kvwriter.LevelError:
+		return SeverityError
+	case kvwriter.LevelCritical:
+		return SeverityCritical
+	case kvwriter.LevelAlert:
+		return SeverityAlert
+	case kvwriter.LevelEmergency:
+		return SeverityEmergency
+	}
+	return SeverityInfo
+}
@@ -0,0 +1,38 @@
+package kvsyslog
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+func TestWrite(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	w := kvwriter.NewKeyValueWriter(kvwriter.WithInputFormat(kvwriter.InputLogfmt))
+	s, err := Dial("udp", conn.LocalAddr().String(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte(`level=warn msg=disk`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var b = make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(b[:n]); !strings.HasPrefix(msg, "<12>1 ") || !strings.HasSuffix(msg, ` - - level="warn" msg="disk"`) {
+		t.Errorf("got %q", msg)
+	}
+}
@@ -0,0 +1,224 @@
+// Package kvzap implements a zapcore.Encoder on top of KeyValueWriter, so zap users get the
+// same console output as services emitting JSON through KeyValueWriter.
+package kvzap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+var bufferPool = buffer.NewPool()
+
+// Encoder is a zapcore.Encoder rendering entries through a KeyValueWriter. Only the keys
+// of the zapcore.EncoderConfig are used, values are formatted by the writer.
+type Encoder struct {
+	w   kvwriter.KeyValueWriter
+	cfg zapcore.EncoderConfig
+
+	fields map[string]interface{}
+	ns     []string // Namespaces opened by OpenNamespace
+}
+
+// NewEncoder creates an Encoder using the keys of cfg as the field names of the writer and
+// writing the time, level, logger name, caller, function and message first, like zap's
+// console encoder. The options are applied after these defaults.
+func NewEncoder(cfg zapcore.EncoderConfig, options ...kvwriter.Option) *Encoder {
+	var opts = []kvwriter.Option{
+		kvwriter.WithTimestampFieldName(cfg.TimeKey),
+		kvwriter.WithLevelFieldName(cfg.LevelKey),
+		kvwriter.WithMessageFieldName(cfg.MessageKey),
+		kvwriter.WithCallerFieldName(cfg.CallerKey),
+		kvwriter.WithFieldsOrder(cfg.TimeKey, cfg.LevelKey, cfg.NameKey, cfg.CallerKey, cfg.FunctionKey, cfg.MessageKey),
+	}
+	return &Encoder{
+		w:      kvwriter.NewKeyValueWriter(append(opts, options...)...),
+		cfg:    cfg,
+		fields: make(map[string]interface{}),
+	}
+}
+
+// Clone copies the encoder, including the fields added so far.
+func (e *Encoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *Encoder) clone() *Encoder {
+	return &Encoder{
+		w:      e.w,
+		cfg:    e.cfg,
+		fields: copyMap(e.fields),
+		ns:     append([]string(nil), e.ns...),
+	}
+}
+
+// EncodeEntry renders the entry and the fields into a single line.
+func (e *Encoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var c = e.clone()
+	for _, f := range fields {
+		f.AddTo(c)
+	}
+
+	var evt = c.fields
+	setField(evt, e.cfg.TimeKey, ent.Time.Format(time.RFC3339Nano))
+	setField(evt, e.cfg.LevelKey, ent.Level.String())
+	if ent.LoggerName != "" {
+		setField(evt, e.cfg.NameKey, ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		setField(evt, e.cfg.CallerKey, ent.Caller.String())
+		setField(evt, e.cfg.FunctionKey, ent.Caller.Function)
+	}
+	setField(evt, e.cfg.MessageKey, ent.Message)
+	if ent.Stack != "" {
+		setField(evt, e.cfg.StacktraceKey, ent.Stack)
+	}
+
+	line, err := e.w.FormatEvent(evt)
+	buf := bufferPool.Get()
+	buf.Write(line)
+	return buf, err
+}
+
+func setField(evt map[string]interface{}, key string, value interface{}) {
+	if key != "" {
+		evt[key] = value
+	}
+}
+
+// cur returns the map of the innermost open namespace.
+func (e *Encoder) cur() map[string]interface{} {
+	var m = e.fields
+	for _, ns := range e.ns {
+		sub, ok := m[ns].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[ns] = sub
+		}
+		m = sub
+	}
+	return m
+}
+
+// AddArray implements zapcore.ObjectEncoder.
+func (e *Encoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddArray(key, v)
+	e.cur()[key] = m.Fields[key]
+	return err
+}
+
+// AddObject implements zapcore.ObjectEncoder.
+func (e *Encoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := v.MarshalLogObject(m)
+	e.cur()[key] = m.Fields
+	return err
+}
+
+// AddBinary implements zapcore.ObjectEncoder. Binary data is base64 encoded.
+func (e *Encoder) AddBinary(key string, v []byte) {
+	e.cur()[key] = base64.StdEncoding.EncodeToString(v)
+}
+
+// AddByteString implements zapcore.ObjectEncoder.
+func (e *Encoder) AddByteString(key string, v []byte) { e.cur()[key] = string(v) }
+
+// AddBool implements zapcore.ObjectEncoder.
+func (e *Encoder) AddBool(key string, v bool) { e.cur()[key] = v }
+
+// AddComplex128 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddComplex128(key string, v complex128) { e.cur()[key] = fmt.Sprint(v) }
+
+// AddComplex64 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddComplex64(key string, v complex64) { e.cur()[key] = fmt.Sprint(v) }
+
+// AddDuration implements zapcore.ObjectEncoder.
+func (e *Encoder) AddDuration(key string, v time.Duration) { e.cur()[key] = v }
+
+// AddFloat64 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddFloat64(key string, v float64) { e.cur()[key] = v }
+
+// AddFloat32 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddFloat32(key string, v float32) { e.cur()[key] = v }
+
+// AddInt implements zapcore.ObjectEncoder.
+func (e *Encoder) AddInt(key string, v int) { e.cur()[key] = v }
+
+// AddInt64 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddInt64(key string, v int64) { e.cur()[key] = v }
+
+// AddInt32 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddInt32(key string, v int32) { e.cur()[key] = v }
+
+// AddInt16 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddInt16(key string, v int16) { e.cur()[key] = v }
+
+// AddInt8 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddInt8(key string, v int8) { e.cur()[key] = v }
+
+// AddString implements zapcore.ObjectEncoder.
+func (e *Encoder) AddString(key, v string) { e.cur()[key] = v }
+
+// AddTime implements zapcore.ObjectEncoder.
+func (e *Encoder) AddTime(key string, v time.Time) { e.cur()[key] = v }
+
+// AddUint implements zapcore.ObjectEncoder.
+func (e *Encoder) AddUint(key string, v uint) { e.cur()[key] = v }
+
+// AddUint64 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddUint64(key string, v uint64) { e.cur()[key] = v }
+
+// AddUint32 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddUint32(key string, v uint32) { e.cur()[key] = v }
+
+// AddUint16 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddUint16(key string, v uint16) { e.cur()[key] = v }
+
+// AddUint8 implements zapcore.ObjectEncoder.
+func (e *Encoder) AddUint8(key string, v uint8) { e.cur()[key] = v }
+
+// AddUintptr implements zapcore.ObjectEncoder.
+func (e *Encoder) AddUintptr(key string, v uintptr) { e.cur()[key] = uint64(v) }
+
+// AddReflected implements zapcore.ObjectEncoder.
+func (e *Encoder) AddReflected(key string, v interface{}) error {
+	e.cur()[key] = v
+	return nil
+}
+
+// OpenNamespace implements zapcore.ObjectEncoder. All following fields are nested in the
+// namespace.
+func (e *Encoder) OpenNamespace(key string) {
+	e.ns = append(e.ns, key)
+}
+
+// copyMap deeply copies the maps and slices of m, which are modified when the event is
+// written.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	var out = make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+func copyValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		return copyMap(vv)
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, elem := range vv {
+			out[i] = copyValue(elem)
+		}
+		return out
+	default:
+		return v
+	}
+}
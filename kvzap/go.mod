@@ -0,0 +1,14 @@
+module github.com/milesich/kv-writer/kvzap
+
+go 1.21
+
+require (
+	github.com/milesich/kv-writer v0.1.0
+	go.uber.org/zap v1.27.0
+)
+
+require (
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+)
@@ -0,0 +1,123 @@
+// Package kvzerolog configures a KeyValueWriter as a drop-in replacement of
+// zerolog.ConsoleWriter.
+package kvzerolog
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	kvwriter "github.com/milesich/kv-writer"
+)
+
+// NewConsoleWriter creates a KeyValueWriter understanding the events written by zerolog.
+// It uses the field names configured in the zerolog package, parses timestamps according
+// to zerolog.TimeFieldFormat, abbreviates zerolog levels and writes the time, level,
+// caller, message and error first, like zerolog.ConsoleWriter. The options are applied
+// after these defaults.
+func NewConsoleWriter(options ...kvwriter.Option) kvwriter.KeyValueWriter {
+	var opts = []kvwriter.Option{
+		kvwriter.WithTimestampFieldName(zerolog.TimestampFieldName),
+		kvwriter.WithLevelFieldName(zerolog.LevelFieldName),
+		kvwriter.WithMessageFieldName(zerolog.MessageFieldName),
+		kvwriter.WithCallerFieldName(zerolog.CallerFieldName),
+		kvwriter.WithFieldsOrder(
+			zerolog.TimestampFieldName,
+			zerolog.LevelFieldName,
+			zerolog.CallerFieldName,
+			zerolog.MessageFieldName,
+			zerolog.ErrorFieldName,
+		),
+		kvwriter.WithTimeLayout(time.Kitchen),
+		kvwriter.WithLevelFormatter(FormatLevel),
+		kvwriter.WithUnquotedMessage(true),
+		kvwriter.WithColorize(kvwriter.ColorAuto),
+	}
+	opts = append(opts, options...)
+	opts = append(opts, func(w *kvwriter.KeyValueWriter) {
+		if w.FormatTimestamp == nil && w.TimeLayout != "" {
+			w.FormatTimestamp = TimestampFormatter(w.TimeLayout)
+		}
+	})
+
+	return kvwriter.NewKeyValueWriter(opts...)
+}
+
+// FormatLevel abbreviates zerolog levels the way zerolog.ConsoleWriter does, e.g. "INF".
+func FormatLevel(i interface{}) string {
+	s, ok := i.(string)
+	if !ok {
+		return "???"
+	}
+	l, err := zerolog.ParseLevel(s)
+	if err != nil || l == zerolog.NoLevel {
+		return kvwriter.AbbreviateLevel(s)
+	}
+	return kvwriter.AbbreviateLevel(l.String())
+}
+
+// TimestampFormatter returns a formatter parsing timestamps written with
+// zerolog.TimeFieldFormat and rendering them in local time using layout. Timestamps that
+// cannot be parsed are written as is.
+func TimestampFormatter(layout string) kvwriter.Formatter {
+	return func(i interface{}) string {
+		t, ok := parseTimestamp(i)
+		if !ok {
+			switch v := i.(type) {
+			case string:
+				return v
+			case json.Number:
+				return v.String()
+			default:
+				return "<nil>"
+			}
+		}
+		return t.Local().Format(layout)
+	}
+}
+
+func parseTimestamp(i interface{}) (time.Time, bool) {
+	switch zerolog.TimeFieldFormat {
+	case zerolog.TimeFormatUnix, zerolog.TimeFormatUnixMs, zerolog.TimeFormatUnixMicro, zerolog.TimeFormatUnixNano:
+		n, ok := i.(json.Number)
+		if !ok {
+			return time.Time{}, false
+		}
+		return parseUnix(n.String(), zerolog.TimeFieldFormat)
+	default:
+		s, ok := i.(string)
+		if !ok {
+			return time.Time{}, false
+		}
+		t, err := time.Parse(zerolog.TimeFieldFormat, s)
+		return t, err == nil
+	}
+}
+
+// parseUnix parses a Unix timestamp in the unit of the zerolog time format. Seconds may
+// have a fractional part.
+func parseUnix(s, format string) (time.Time, bool) {
+	if format == zerolog.TimeFormatUnix {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*float64(time.Second))), true
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch format {
+	case zerolog.TimeFormatUnixMs:
+		return time.UnixMilli(n), true
+	case zerolog.TimeFormatUnixMicro:
+		return time.UnixMicro(n), true
+	default:
+		return time.Unix(0, n), true
+	}
+}
@@ -0,0 +1,217 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Level is the severity of an event, ordered from the least to the most severe.
+type Level int
+
+const (
+	// LevelUnset is the level of events without a known level.
+	LevelUnset Level = iota
+	LevelTrace
+	LevelDebug
+	LevelInfo
+	LevelNotice
+	LevelWarn
+	LevelError
+	LevelCritical
+	LevelAlert
+	LevelEmergency
+
+	// LevelFatal and LevelPanic are the levels of other names for LevelCritical and
+	// LevelEmergency.
+	LevelFatal = LevelCritical
+	LevelPanic = LevelEmergency
+)
+
+var levelNames = [...]string{
+	LevelUnset:     "",
+	LevelTrace:     "trace",
+	LevelDebug:     "debug",
+	LevelInfo:      "info",
+	LevelNotice:    "notice",
+	LevelWarn:      "warn",
+	LevelError:     "error",
+	LevelCritical:  "critical",
+	LevelAlert:     "alert",
+	LevelEmergency: "emergency",
+}
+
+// String returns the lower-cased name of the level.
+func (l Level) String() string {
+	if l < LevelUnset || l > LevelEmergency {
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// DefaultLevels maps lower-cased level names and abbreviations of common loggers to their
+// levels.
+var DefaultLevels = map[string]Level{
+	"trace":       LevelTrace,
+	"trc":         LevelTrace,
+	"debug":       LevelDebug,
+	"dbg":         LevelDebug,
+	"info":        LevelInfo,
+	"information": LevelInfo,
+	"inf":         LevelInfo,
+	"notice":      LevelNotice,
+	"ntc":         LevelNotice,
+	"warn":        LevelWarn,
+	"warning":     LevelWarn,
+	"wrn":         LevelWarn,
+	"error":       LevelError,
+	"err":         LevelError,
+	"critical":    LevelCritical,
+	"crit":        LevelCritical,
+	"crt":         LevelCritical,
+	"fatal":       LevelFatal,
+	"ftl":         LevelFatal,
+	"alert":       LevelAlert,
+	"alr":         LevelAlert,
+	"emergency":   LevelEmergency,
+	"emerg":       LevelEmergency,
+	"emr":         LevelEmergency,
+	"panic":       LevelPanic,
+	"pnc":         LevelPanic,
+}
+
+// numericLevels are the levels of the bunyan and pino numeric levels 10 to 60.
+var numericLevels = [...]Level{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}
+
+// ParseLevel parses a level name using DefaultLevels, case-insensitively. Numeric levels
+// of bunyan and pino (10 trace to 60 fatal) are supported as well.
+func ParseLevel(level string) (Level, bool) {
+	return parseLevel(DefaultLevels, level)
+}
+
+func parseLevel(table map[string]Level, level string) (Level, bool) {
+	if l, ok := table[strings.ToLower(level)]; ok {
+		return l, true
+	}
+	if n, err := strconv.Atoi(level); err == nil && n >= 10 && n <= 60 && n%10 == 0 {
+		return numericLevels[n/10-1], true
+	}
+	return LevelUnset, false
+}
+
+// EventLevel returns the level of the event according to LevelFieldName and LevelTable,
+// or LevelUnset if it has none.
+func (w KeyValueWriter) EventLevel(evt map[string]interface{}) Level {
+	var s string
+	switch v := evt[w.LevelFieldName].(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	default:
+		return LevelUnset
+	}
+
+	var table = w.LevelTable
+	if table == nil {
+		table = DefaultLevels
+	}
+	l, _ := parseLevel(table, s)
+	return l
+}
+
+// levelAbbreviations maps lower-cased level names to their three letter abbreviation.
+var levelAbbreviations = map[string]string{
+	"trace":       "TRC",
+	"debug":       "DBG",
+	"info":        "INF",
+	"information": "INF",
+	"notice":      "NTC",
+	"warn":        "WRN",
+	"warning":     "WRN",
+	"error":       "ERR",
+	"err":         "ERR",
+	"critical":    "CRT",
+	"crit":        "CRT",
+	"alert":       "ALR",
+	"emergency":   "EMR",
+	"fatal":       "FTL",
+	"panic":       "PNC",
+}
+
+// AbbreviateLevel returns the three letter abbreviation of level, e.g. "INF" for
+// "information". Unknown levels are returned upper-cased.
+func AbbreviateLevel(level string) string {
+	if abbr, ok := levelAbbreviations[strings.ToLower(level)]; ok {
+		return abbr
+	}
+	return strings.ToUpper(level)
+}
+
+// LevelIconMode defines how level icons are written.
+type LevelIconMode int
+
+const (
+	// LevelIconsOff writes levels without icons.
+	LevelIconsOff LevelIconMode = iota
+	// LevelIconsPrefix writes the icon and a space before the level.
+	LevelIconsPrefix
+	// LevelIconsReplace writes the icon instead of the level. Levels without an icon are
+	// written as is.
+	LevelIconsReplace
+)
+
+// DefaultLevelIcons maps level names to the icons used when LevelIconSymbols is nil.
+var DefaultLevelIcons = map[string]string{
+	"trace": "·",
+	"debug": "●",
+	"info":  "ℹ",
+	"warn":  "⚠",
+	"error": "✖",
+	"fatal": "✖",
+	"panic": "✖",
+}
+
+// levelIcon returns the icon of the level, matched like levelColor.
+func (w KeyValueWriter) levelIcon(level string) string {
+	var icons = w.LevelIconSymbols
+	if icons == nil {
+		icons = DefaultLevelIcons
+	}
+
+	if icon, ok := icons[strings.ToLower(level)]; ok {
+		return icon
+	}
+	abbr := AbbreviateLevel(level)
+	for name, icon := range icons {
+		if AbbreviateLevel(name) == abbr {
+			return icon
+		}
+	}
+	return ""
+}
+
+// defaultFormatLevel abbreviates the level when AbbreviateLevels is enabled, adds its
+// icon according to LevelIcons and pads it to LevelWidth.
+func (w KeyValueWriter) defaultFormatLevel(i interface{}) string {
+	var level = defaultFormatValue(i)
+	var icon string
+	if w.LevelIcons != LevelIconsOff {
+		icon = w.levelIcon(level)
+	}
+	if w.AbbreviateLevels {
+		level = AbbreviateLevel(level)
+	}
+	if icon != "" {
+		if w.LevelIcons == LevelIconsReplace {
+			level = icon
+		} else {
+			level = icon + " " + level
+		}
+	}
+	if n := utf8.RuneCountInString(level); n < w.LevelWidth {
+		level += strings.Repeat(" ", w.LevelWidth-n)
+	}
+	return level
+}
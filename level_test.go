@@ -0,0 +1,32 @@
+package kvwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLevelIcons(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		options []Option
+		want    string
+	}{
+		{"prefix", "info", []Option{WithLevelIcons(LevelIconsPrefix)}, `level="ℹ info"` + "\n"},
+		{"replace", "info", []Option{WithLevelIcons(LevelIconsReplace)}, `level="ℹ"` + "\n"},
+		{"abbreviated", "info", []Option{WithLevelIcons(LevelIconsPrefix), WithAbbreviatedLevels(true)}, `level="ℹ INF"` + "\n"},
+		{"unknown", "weird", []Option{WithLevelIcons(LevelIconsReplace)}, `level="weird"` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewKeyValueWriter(append(tt.options, WithOutput(&out))...)
+			if _, err := w.Write([]byte(`{"level":"` + tt.level + `"}`)); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package kvwriter
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// LineOverflow defines what happens when a line exceeds MaxLineLength.
+type LineOverflow int
+
+const (
+	// OverflowWrap continues the pairs on the next line prefixed with WrapIndent.
+	OverflowWrap LineOverflow = iota
+	// OverflowElide drops the pairs that do not fit and appends "+N more fields".
+	OverflowElide
+)
+
+// lineLimiter keeps the lines written by writePairs within MaxLineLength.
+type lineLimiter struct {
+	max    int
+	mode   LineOverflow
+	indent string
+	pd     string
+
+	width  int // Visible width of the current line
+	onLine int // Number of pairs on the current line
+}
+
+func (w KeyValueWriter) newLineLimiter(pd string) *lineLimiter {
+	if w.MaxLineLength <= 0 || w.Multiline {
+		return nil
+	}
+	return &lineLimiter{
+		max:    w.MaxLineLength,
+		mode:   w.LineOverflow,
+		indent: w.WrapIndent,
+		pd:     pd,
+	}
+}
+
+// fit is called after a pair was written to buf, with delimStart being the offset of the
+// delimiter preceding it and pairStart the offset of the pair. It wraps the pair onto a
+// new line or elides it together with the remaining pairs. It reports whether more pairs
+// may be written and returns the new offset of the pair.
+func (l *lineLimiter) fit(buf *bytes.Buffer, delimStart, pairStart, remaining int) (int, bool) {
+	var added = visibleWidth(buf.Bytes()[delimStart:])
+	if l.onLine == 0 || l.width+added <= l.max {
+		l.width += added
+		l.onLine++
+		return pairStart, true
+	}
+
+	if l.mode == OverflowElide {
+		buf.Truncate(delimStart)
+		buf.WriteString(l.pd)
+		buf.WriteString("+" + strconv.Itoa(remaining) + " more ")
+		if remaining == 1 {
+			buf.WriteString("field")
+		} else {
+			buf.WriteString("fields")
+		}
+		return pairStart, false
+	}
+
+	var pair = append([]byte(nil), buf.Bytes()[pairStart:]...)
+	buf.Truncate(delimStart)
+	buf.WriteByte('\n')
+	buf.WriteString(l.indent)
+	pairStart = buf.Len()
+	buf.Write(pair)
+	l.width = visibleWidth(buf.Bytes()[delimStart+1:])
+	l.onLine = 1
+	return pairStart, true
+}
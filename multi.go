@@ -0,0 +1,119 @@
+package kvwriter
+
+import (
+	"bytes"
+	"errors"
+)
+
+// MultiWriter decodes the input once and writes the events through several writers, each
+// with its own filters and formatting, e.g. colored compact output to stdout and full
+// uncolored output to a file. The input is decoded with the configuration of the first
+// writer. Every writer but the last one gets a deep copy of the events, as writers may
+// modify them.
+type MultiWriter struct {
+	Writers []KeyValueWriter
+}
+
+// NewMultiWriter creates a MultiWriter writing through writers.
+func NewMultiWriter(writers ...KeyValueWriter) MultiWriter {
+	return MultiWriter{Writers: writers}
+}
+
+// Write decodes p and writes the events through all writers, joining their errors.
+func (m MultiWriter) Write(p []byte) (int, error) {
+	if len(m.Writers) == 0 {
+		return len(p), nil
+	}
+
+	var buf = getBuffer(len(p))
+	defer putBuffer(buf, m.Writers[0].MaxBufferSize)
+
+	events, err := m.Writers[0].decode(p)
+	if err != nil {
+		return m.Writers[0].decodeError(p, err, buf)
+	}
+	defer m.Writers[0].releaseEvents(events)
+	return len(p), m.writeEvents(events, buf)
+}
+
+// WriteEvent writes the event through all writers like KeyValueWriter.WriteEvent.
+func (m MultiWriter) WriteEvent(evt map[string]interface{}) error {
+	if len(m.Writers) == 0 {
+		return nil
+	}
+
+	var buf = getBuffer(0)
+	defer putBuffer(buf, m.Writers[0].MaxBufferSize)
+
+	normalizeMap(evt)
+	return m.writeEvents([]map[string]interface{}{evt}, buf)
+}
+
+func (m MultiWriter) writeEvents(events []map[string]interface{}, buf *bytes.Buffer) error {
+	var errs []error
+	for i, w := range m.Writers {
+		for _, evt := range events {
+			if i < len(m.Writers)-1 {
+				evt = copyMap(evt)
+			}
+			ok, err := w.renderEvent(evt, buf)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			w.countEvent(ok)
+		}
+		if buf.Len() > 0 {
+			if err := w.writeOut(buf); err != nil {
+				errs = append(errs, err)
+			}
+			buf.Reset()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush flushes all writers.
+func (m MultiWriter) Flush() error {
+	var errs []error
+	for _, w := range m.Writers {
+		if err := w.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all writers.
+func (m MultiWriter) Close() error {
+	var errs []error
+	for _, w := range m.Writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copyMap returns a deep copy of the decoded object m.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	var c = make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyMap(v)
+	case []interface{}:
+		var c = make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = copyValue(e)
+		}
+		return c
+	}
+	return v
+}
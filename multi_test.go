@@ -0,0 +1,13 @@
+package kvwriter
+
+import "testing"
+
+func TestMultiWriterWithoutWriters(t *testing.T) {
+	var m MultiWriter
+	if _, err := m.Write([]byte(`{"a":1}`)); err != nil {
+		t.Errorf("Write: %v", err)
+	}
+	if err := m.WriteEvent(map[string]interface{}{"a": 1}); err != nil {
+		t.Errorf("WriteEvent: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package kvwriter
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// writeNested appends the event to buf preserving its hierarchy, e.g.
+// 'http={method="GET" status="200"}'. Keys are filtered, ordered and formatted by their
+// flattened names.
+func (w KeyValueWriter) writeNested(evt map[string]interface{}, buf *bytes.Buffer) {
+	fk, fv := w.formatters()
+	w.writeNestedObject(buf, evt, "", fk, fv)
+}
+
+// writeNestedObject appends the pairs of obj nested in the flattened key parent.
+func (w KeyValueWriter) writeNestedObject(buf *bytes.Buffer, obj map[string]interface{}, parent string, fk, fv Formatter) {
+	var paths = make([]string, 0, len(obj))
+	var names = make(map[string]string, len(obj))
+	for name, value := range obj {
+		path := w.joinKey(parent, name)
+		if w.keepNested(path, value) {
+			paths = append(paths, path)
+			names[path] = name
+		}
+	}
+	w.sortKeys(paths)
+
+	pd := w.pairsDelimiter()
+	for i, path := range paths {
+		if i > 0 {
+			buf.WriteString(pd)
+		}
+		w.writeKey(buf, formatKey(fk, names[path]))
+		w.writeNestedValue(buf, path, obj[names[path]], fk, fv)
+	}
+}
+
+// writeNestedValue appends value, recursing into objects and arrays.
+func (w KeyValueWriter) writeNestedValue(buf *bytes.Buffer, path string, value interface{}, fk, fv Formatter) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			w.writeValue(buf, path, v, fv)
+			return
+		}
+		buf.WriteByte('{')
+		w.writeNestedObject(buf, v, path, fk, fv)
+		buf.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			w.writeValue(buf, path, v, fv)
+			return
+		}
+		pd := w.pairsDelimiter()
+		buf.WriteByte('[')
+		var written int
+		for i, elem := range v {
+			elemPath := w.joinKey(path, strconv.Itoa(i))
+			if !w.keepNested(elemPath, elem) {
+				continue
+			}
+			if written > 0 {
+				buf.WriteString(pd)
+			}
+			w.writeNestedValue(buf, elemPath, elem, fk, fv)
+			written++
+		}
+		buf.WriteByte(']')
+	default:
+		w.writeValue(buf, path, v, fv)
+	}
+}
+
+// keepNested reports whether the value at the flattened path is written, which for
+// objects and arrays means that at least one of their leaves is kept.
+func (w KeyValueWriter) keepNested(path string, value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return w.keepKey(path)
+		}
+		for name, child := range v {
+			if w.keepNested(w.joinKey(path, name), child) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		if len(v) == 0 {
+			return w.keepKey(path)
+		}
+		for i, child := range v {
+			if w.keepNested(w.joinKey(path, strconv.Itoa(i)), child) {
+				return true
+			}
+		}
+		return false
+	default:
+		return w.keepKey(path)
+	}
+}
@@ -0,0 +1,132 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// maxExpandedExponent limits the exponents expanded by ExpandExponent.
+const maxExpandedExponent = 100
+
+// NumberFormat defines how JSON numbers are rendered by the default value formatter. The
+// zero value keeps the numbers as they are in the input.
+type NumberFormat struct {
+	// FixedDecimals rounds floats to Decimals decimal places.
+	FixedDecimals bool
+
+	// Decimals is the number of decimal places used by FixedDecimals.
+	Decimals int
+
+	// ThousandsSeparator is inserted between groups of three integer digits, e.g. ",".
+	ThousandsSeparator string
+
+	// ExpandExponent writes numbers in scientific notation in decimal notation.
+	ExpandExponent bool
+}
+
+// formatter returns the default value formatter applying the number format.
+func (f NumberFormat) formatter() Formatter {
+	return func(i interface{}) string {
+		if n, ok := i.(json.Number); ok {
+			return f.format(n.String())
+		}
+		return defaultFormatValue(i)
+	}
+}
+
+// format formats the number literal s.
+func (f NumberFormat) format(s string) string {
+	if f.ExpandExponent {
+		s = expandExponent(s)
+	}
+	if f.FixedDecimals && strings.ContainsAny(s, ".eE") {
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			s = strconv.FormatFloat(v, 'f', f.Decimals, 64)
+		}
+	}
+	if f.ThousandsSeparator != "" {
+		s = groupThousands(s, f.ThousandsSeparator)
+	}
+	return s
+}
+
+// expandExponent rewrites the number literal s in scientific notation in decimal notation
+// without losing precision, e.g. 1.5e3 to 1500. Trailing fractional zeros are dropped.
+func expandExponent(s string) string {
+	e := strings.IndexAny(s, "eE")
+	if e < 0 {
+		return s
+	}
+	exp, err := strconv.Atoi(s[e+1:])
+	if err != nil || exp > maxExpandedExponent || exp < -maxExpandedExponent {
+		return s
+	}
+
+	var sign, mant = "", s[:e]
+	if mant != "" && (mant[0] == '-' || mant[0] == '+') {
+		sign, mant = mant[:1], mant[1:]
+		if sign == "+" {
+			sign = ""
+		}
+	}
+
+	point := len(mant)
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		point = i
+		mant = mant[:i] + mant[i+1:]
+	}
+	point += exp
+
+	var intPart, fracPart string
+	switch {
+	case point <= 0:
+		intPart, fracPart = "0", strings.Repeat("0", -point)+mant
+	case point >= len(mant):
+		intPart = mant + strings.Repeat("0", point-len(mant))
+	default:
+		intPart, fracPart = mant[:point], mant[point:]
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	fracPart = strings.TrimRight(fracPart, "0")
+	if fracPart == "" {
+		return sign + intPart
+	}
+	return sign + intPart + "." + fracPart
+}
+
+// groupThousands inserts sep between groups of three digits of the integer part of the
+// decimal number literal s. Literals in scientific notation are left unchanged.
+func groupThousands(s, sep string) string {
+	if strings.ContainsAny(s, "eE") {
+		return s
+	}
+
+	var start = 0
+	if start < len(s) && (s[0] == '-' || s[0] == '+') {
+		start = 1
+	}
+	end := strings.IndexByte(s, '.')
+	if end < 0 {
+		end = len(s)
+	}
+	if end-start <= 3 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + (end-start)/3*len(sep))
+	b.WriteString(s[:start])
+	for i := start; i < end; i++ {
+		if i > start && (end-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteString(s[end:])
+	return b.String()
+}
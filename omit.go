@@ -0,0 +1,65 @@
+package kvwriter
+
+import "encoding/json"
+
+// NullMode defines how null values are rendered.
+type NullMode int
+
+const (
+	// NullLiteral writes null values as an unquoted null.
+	NullLiteral NullMode = iota
+	// NullOmit drops keys with null values.
+	NullOmit
+	// NullEmpty writes null values as an empty value.
+	NullEmpty
+	// NullPlaceholder writes null values as the unquoted NullText.
+	NullPlaceholder
+)
+
+// nullString returns the rendering of null values.
+func (w KeyValueWriter) nullString() string {
+	switch w.NullMode {
+	case NullEmpty:
+		return ""
+	case NullPlaceholder:
+		return w.NullText
+	}
+	return "null"
+}
+
+// omitValues deletes the keys of m and its nested objects whose values match omit. Nested
+// objects are checked after their keys were deleted.
+func omitValues(m map[string]interface{}, omit func(v interface{}) bool) {
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			omitValues(nested, omit)
+		}
+		if omit(v) {
+			delete(m, k)
+		}
+	}
+}
+
+func isNull(v interface{}) bool {
+	return v == nil
+}
+
+// isEmpty reports whether v is "", 0, false, null or an empty array or object.
+func isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
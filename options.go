@@ -0,0 +1,874 @@
+package kvwriter
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Option configures a KeyValueWriter. Options are applied in order by NewKeyValueWriter
+// and the resulting configuration is validated once all of them have run.
+type Option func(w *KeyValueWriter)
+
+// WithOutput sets the output destination.
+func WithOutput(out io.Writer) Option {
+	return func(w *KeyValueWriter) {
+		w.Out = out
+	}
+}
+
+// WithFallbackOutput sets the destination of the lines that could not be written to the
+// output.
+func WithFallbackOutput(out io.Writer) Option {
+	return func(w *KeyValueWriter) {
+		w.FallbackOut = out
+	}
+}
+
+// WithWriteErrorHandler sets the function called with the errors of writing to the
+// output.
+func WithWriteErrorHandler(f func(err error)) Option {
+	return func(w *KeyValueWriter) {
+		w.OnWriteError = f
+	}
+}
+
+// WithLocking enables or disables serializing the writes to Out.
+func WithLocking(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.Locking = enabled
+	}
+}
+
+// WithBatching buffers the output until it reaches size bytes or interval passed.
+func WithBatching(size int, interval time.Duration) Option {
+	return func(w *KeyValueWriter) {
+		w.FlushSize = size
+		w.FlushInterval = interval
+	}
+}
+
+// WithMaxBufferSize sets the largest capacity of the formatting buffers reused by later
+// writes.
+func WithMaxBufferSize(n int) Option {
+	return func(w *KeyValueWriter) {
+		w.MaxBufferSize = n
+	}
+}
+
+// WithInputFormat sets the format of the input passed to Write.
+func WithInputFormat(f InputFormat) Option {
+	return func(w *KeyValueWriter) {
+		w.InputFormat = f
+	}
+}
+
+// WithFastPath enables or disables writing JSON objects without decoding them into maps,
+// when the configuration allows it.
+func WithFastPath(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.FastPath = enabled
+	}
+}
+
+// WithOutputFormat sets the format of the written events.
+func WithOutputFormat(f OutputFormat) Option {
+	return func(w *KeyValueWriter) {
+		w.OutputFormat = f
+	}
+}
+
+// WithColumns sets the keys written by OutputCSV and OutputTSV.
+func WithColumns(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.Columns = keys
+	}
+}
+
+// WithExtraColumn sets the name of the column holding the other pairs as a JSON object.
+func WithExtraColumn(name string) Option {
+	return func(w *KeyValueWriter) {
+		w.ExtraColumn = name
+	}
+}
+
+// WithColumnsHeader enables or disables writing the column names as the first record.
+func WithColumnsHeader(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.ColumnsHeader = enabled
+	}
+}
+
+// WithTableRows sets the number of events OutputTable buffers to size the columns.
+func WithTableRows(n int) Option {
+	return func(w *KeyValueWriter) {
+		w.TableRows = n
+	}
+}
+
+// WithColumnWidths declares the widths of the columns of OutputTable.
+func WithColumnWidths(widths ...int) Option {
+	return func(w *KeyValueWriter) {
+		w.ColumnWidths = widths
+	}
+}
+
+// WithArrayInput sets how top-level JSON arrays are decoded.
+func WithArrayInput(m ArrayInputMode) Option {
+	return func(w *KeyValueWriter) {
+		w.ArrayInput = m
+	}
+}
+
+// WithScalarMode sets how top-level JSON scalars and null are decoded.
+func WithScalarMode(m ScalarMode) Option {
+	return func(w *KeyValueWriter) {
+		w.ScalarMode = m
+	}
+}
+
+// WithScalarKey sets the key top-level scalars are written under.
+func WithScalarKey(key string) Option {
+	return func(w *KeyValueWriter) {
+		w.ScalarKey = key
+	}
+}
+
+// WithDecoder sets the Decoder used to decode the input, replacing the input format.
+func WithDecoder(d Decoder) Option {
+	return func(w *KeyValueWriter) {
+		w.Decoder = d
+	}
+}
+
+// WithJSONDecoder sets the function creating the decoder of JSON input.
+func WithJSONDecoder(f func(r io.Reader) JSONDecoder) Option {
+	return func(w *KeyValueWriter) {
+		w.NewJSONDecoder = f
+	}
+}
+
+// WithErrorMode sets what happens to input that cannot be decoded.
+func WithErrorMode(m ErrorMode) Option {
+	return func(w *KeyValueWriter) {
+		w.ErrorMode = m
+	}
+}
+
+// WithPassThroughInvalid writes input that cannot be decoded unchanged, prefixed with
+// prefix, instead of returning an error.
+func WithPassThroughInvalid(prefix string) Option {
+	return func(w *KeyValueWriter) {
+		w.ErrorMode = ErrorPassThrough
+		w.PassThroughPrefix = prefix
+	}
+}
+
+// WithDecodeErrorHandler sets a function handling input that cannot be decoded.
+func WithDecodeErrorHandler(f func(p []byte, err error) ([]byte, error)) Option {
+	return func(w *KeyValueWriter) {
+		w.OnDecodeError = f
+	}
+}
+
+// WithPairsDelimiter sets the character used to delimit individual pairs.
+func WithPairsDelimiter(r rune) Option {
+	return func(w *KeyValueWriter) {
+		w.PairsDelimiter = r
+	}
+}
+
+// WithKeyValueDelimiter sets the character used to delimit key and value.
+func WithKeyValueDelimiter(r rune) Option {
+	return func(w *KeyValueWriter) {
+		w.KeyValueDelimiter = r
+	}
+}
+
+// WithPairsSeparator sets the string used to delimit individual pairs.
+func WithPairsSeparator(sep string) Option {
+	return func(w *KeyValueWriter) {
+		w.PairsSeparator = sep
+	}
+}
+
+// WithKeyValueSeparator sets the string used to delimit key and value.
+func WithKeyValueSeparator(sep string) Option {
+	return func(w *KeyValueWriter) {
+		w.KeyValueSeparator = sep
+	}
+}
+
+// WithQuoteValues enables or disables quoting of values.
+func WithQuoteValues(q bool) Option {
+	return func(w *KeyValueWriter) {
+		w.QuoteValues = q
+	}
+}
+
+// WithPreserveTypes sets whether numbers and booleans are written unquoted.
+func WithPreserveTypes(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.PreserveTypes = enabled
+	}
+}
+
+// WithQuoteMode sets which values are quoted.
+func WithQuoteMode(m QuoteMode) Option {
+	return func(w *KeyValueWriter) {
+		w.QuoteMode = m
+	}
+}
+
+// WithQuoteKeys sets whether all keys are quoted.
+func WithQuoteKeys(q bool) Option {
+	return func(w *KeyValueWriter) {
+		w.QuoteKeys = q
+	}
+}
+
+// WithQuoteChar sets the character quoting keys and values: a double quote, a single quote
+// or a backtick.
+func WithQuoteChar(c rune) Option {
+	return func(w *KeyValueWriter) {
+		w.QuoteChar = c
+	}
+}
+
+// WithTemplate sets the text/template defining the layout of the lines.
+func WithTemplate(text string) Option {
+	return func(w *KeyValueWriter) {
+		w.Template = text
+	}
+}
+
+// WithLogfmtMode enables or disables strictly valid logfmt output.
+func WithLogfmtMode(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.LogfmtMode = enabled
+	}
+}
+
+// WithKeysExclude appends keys to not display in output.
+func WithKeysExclude(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.KeysExclude = append(w.KeysExclude, keys...)
+	}
+}
+
+// WithKeysInclude appends keys to display in output, switching the writer into allowlist mode.
+func WithKeysInclude(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.KeysInclude = append(w.KeysInclude, keys...)
+	}
+}
+
+// WithKeysExcludeRegex appends regular expressions matching keys to not display in output.
+func WithKeysExcludeRegex(res ...*regexp.Regexp) Option {
+	return func(w *KeyValueWriter) {
+		w.KeysExcludeRegex = append(w.KeysExcludeRegex, res...)
+	}
+}
+
+// WithKeysIncludeRegex appends regular expressions matching keys to display in output,
+// switching the writer into allowlist mode.
+func WithKeysIncludeRegex(res ...*regexp.Regexp) Option {
+	return func(w *KeyValueWriter) {
+		w.KeysIncludeRegex = append(w.KeysIncludeRegex, res...)
+	}
+}
+
+// WithFieldsOrder sets keys that are always written first, in the given order.
+func WithFieldsOrder(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.FieldsOrder = keys
+	}
+}
+
+// WithKeySort sets the comparator ordering keys not listed in FieldsOrder.
+func WithKeySort(less func(a, b string) bool) Option {
+	return func(w *KeyValueWriter) {
+		w.KeySort = less
+	}
+}
+
+// WithAlignValues enables or disables padding pairs to their column width. A width of 0
+// detects the column widths from the written lines.
+func WithAlignValues(enabled bool, width int) Option {
+	return func(w *KeyValueWriter) {
+		w.AlignValues = enabled
+		w.AlignWidth = width
+	}
+}
+
+// WithMultiline enables or disables writing every pair on its own line with the given
+// indentation.
+func WithMultiline(enabled bool, indent string) Option {
+	return func(w *KeyValueWriter) {
+		w.Multiline = enabled
+		w.MultilineIndent = indent
+	}
+}
+
+// WithJSONStrings sets how string values holding JSON objects or arrays are written.
+func WithJSONStrings(mode JSONStringMode) Option {
+	return func(w *KeyValueWriter) {
+		w.JSONStrings = mode
+	}
+}
+
+// WithErrorKeys detects error objects in the values of keys, or glob patterns, and writes
+// their chain as a single value.
+func WithErrorKeys(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.ErrorKeys = append(w.ErrorKeys, keys...)
+	}
+}
+
+// WithExpandedErrors enables or disables writing the messages of error chains as separate
+// keys.
+func WithExpandedErrors(expand bool) Option {
+	return func(w *KeyValueWriter) {
+		w.ExpandErrors = expand
+	}
+}
+
+// WithErrorFormatter sets the formatter of error chains.
+func WithErrorFormatter(f func(messages []string) string) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatError = f
+	}
+}
+
+// WithStackTraceKeys writes the values of keys, or glob patterns, as stack traces on
+// continuation lines.
+func WithStackTraceKeys(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.StackTraceKeys = append(w.StackTraceKeys, keys...)
+	}
+}
+
+// WithStackTraceIndent sets the indentation of stack traces.
+func WithStackTraceIndent(indent string) Option {
+	return func(w *KeyValueWriter) {
+		w.StackTraceIndent = indent
+	}
+}
+
+// WithStackTracePathSegments sets the number of path segments kept in stack traces.
+func WithStackTracePathSegments(n int) Option {
+	return func(w *KeyValueWriter) {
+		w.StackTracePathSegments = n
+	}
+}
+
+// WithEventSeparator sets the line written after every event in Multiline mode.
+func WithEventSeparator(sep string) Option {
+	return func(w *KeyValueWriter) {
+		w.EventSeparator = sep
+	}
+}
+
+// WithFlattenStyle sets how the keys of nested objects are joined.
+func WithFlattenStyle(style FlattenStyle) Option {
+	return func(w *KeyValueWriter) {
+		w.FlattenStyle = style
+	}
+}
+
+// WithFlattenSeparator sets a custom string joining the keys of nested objects.
+func WithFlattenSeparator(sep string) Option {
+	return func(w *KeyValueWriter) {
+		w.FlattenSeparator = sep
+	}
+}
+
+// WithCollisionMode sets what happens when flattening results in a key that is already set.
+func WithCollisionMode(m CollisionMode) Option {
+	return func(w *KeyValueWriter) {
+		w.CollisionMode = m
+	}
+}
+
+// WithMaxDepth sets the number of levels the event is flattened to.
+func WithMaxDepth(depth int) Option {
+	return func(w *KeyValueWriter) {
+		w.MaxDepth = depth
+	}
+}
+
+// WithArrayMode sets how arrays are written.
+func WithArrayMode(mode ArrayMode) Option {
+	return func(w *KeyValueWriter) {
+		w.ArrayMode = mode
+	}
+}
+
+// WithArrayJoin sets the string joining array elements in ArrayJoinValues mode.
+func WithArrayJoin(join string) Option {
+	return func(w *KeyValueWriter) {
+		w.ArrayJoin = join
+	}
+}
+
+// WithNested enables or disables writing objects and arrays without flattening them.
+func WithNested(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.Nested = enabled
+	}
+}
+
+// WithKeyCase sets the case keys are converted to.
+func WithKeyCase(c KeyCase) Option {
+	return func(w *KeyValueWriter) {
+		w.KeyCase = c
+	}
+}
+
+// WithKeyRename renames the flattened key from to to.
+func WithKeyRename(from, to string) Option {
+	return func(w *KeyValueWriter) {
+		if w.KeysRename == nil {
+			w.KeysRename = make(map[string]string)
+		}
+		w.KeysRename[from] = to
+	}
+}
+
+// WithKeyTrimPrefixes appends prefixes removed from flattened keys.
+func WithKeyTrimPrefixes(prefixes ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.KeyTrimPrefixes = append(w.KeyTrimPrefixes, prefixes...)
+	}
+}
+
+// WithMaxValueLength sets the number of runes values are truncated to and the ellipsis
+// appended to them.
+func WithMaxValueLength(n int, ellipsis string) Option {
+	return func(w *KeyValueWriter) {
+		w.MaxValueLength = n
+		w.Ellipsis = ellipsis
+	}
+}
+
+// WithTruncatedBytes enables or disables appending the number of removed bytes to
+// truncated values.
+func WithTruncatedBytes(show bool) Option {
+	return func(w *KeyValueWriter) {
+		w.ShowTruncatedBytes = show
+	}
+}
+
+// WithMaxLineLength sets the maximum visible width of lines and what happens with the
+// pairs exceeding it.
+func WithMaxLineLength(n int, overflow LineOverflow) Option {
+	return func(w *KeyValueWriter) {
+		w.MaxLineLength = n
+		w.LineOverflow = overflow
+	}
+}
+
+// WithWrapIndent sets the prefix of continuation lines in OverflowWrap mode.
+func WithWrapIndent(indent string) Option {
+	return func(w *KeyValueWriter) {
+		w.WrapIndent = indent
+	}
+}
+
+// WithFilterEvent appends a FilterTransformer to the pipeline, dropping the flattened
+// events for which f returns false.
+func WithFilterEvent(f func(map[string]interface{}) bool) Option {
+	return WithTransformers(FilterTransformer(f))
+}
+
+// WithTransformers appends transformers to the pipeline transforming flattened events.
+func WithTransformers(t ...Transformer) Option {
+	return func(w *KeyValueWriter) {
+		w.Pipeline = append(w.Pipeline, t...)
+	}
+}
+
+// WithTimestampFieldName sets the key holding the event timestamp.
+func WithTimestampFieldName(name string) Option {
+	return func(w *KeyValueWriter) {
+		w.TimestampFieldName = name
+	}
+}
+
+// WithTimeLayout sets the layout used to render the timestamp.
+func WithTimeLayout(layout string) Option {
+	return func(w *KeyValueWriter) {
+		w.TimeLayout = layout
+	}
+}
+
+// WithTimestampFormatter sets the formatter applied to the timestamp field.
+func WithTimestampFormatter(f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatTimestamp = f
+	}
+}
+
+// WithLevelFieldName sets the key holding the event level.
+func WithLevelFieldName(name string) Option {
+	return func(w *KeyValueWriter) {
+		w.LevelFieldName = name
+	}
+}
+
+// WithMinLevel drops events whose level is below l.
+func WithMinLevel(l Level) Option {
+	return func(w *KeyValueWriter) {
+		w.MinLevel = l
+	}
+}
+
+// WithSampler sets the sampler deciding whether events below LevelError are written.
+func WithSampler(s Sampler) Option {
+	return func(w *KeyValueWriter) {
+		w.Sampler = s
+	}
+}
+
+// WithRateLimit suppresses events beyond n per period with the same value of key.
+func WithRateLimit(key string, n int, period time.Duration) Option {
+	return func(w *KeyValueWriter) {
+		w.RateLimitKey = key
+		w.RateLimit = n
+		w.RateLimitPeriod = period
+	}
+}
+
+// WithDedup enables or disables collapsing consecutive identical lines.
+func WithDedup(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.Dedup = enabled
+	}
+}
+
+// WithLevelTable sets the table mapping lower-cased level names to levels.
+func WithLevelTable(table map[string]Level) Option {
+	return func(w *KeyValueWriter) {
+		w.LevelTable = table
+	}
+}
+
+// WithAbbreviatedLevels enables or disables shortening of level values to three letters.
+func WithAbbreviatedLevels(abbreviate bool) Option {
+	return func(w *KeyValueWriter) {
+		w.AbbreviateLevels = abbreviate
+	}
+}
+
+// WithLevelWidth sets the width level values are padded to.
+func WithLevelWidth(width int) Option {
+	return func(w *KeyValueWriter) {
+		w.LevelWidth = width
+	}
+}
+
+// WithLevelIcons sets how level icons are written.
+func WithLevelIcons(mode LevelIconMode) Option {
+	return func(w *KeyValueWriter) {
+		w.LevelIcons = mode
+	}
+}
+
+// WithLevelIcon sets the icon of the given level.
+func WithLevelIcon(level, icon string) Option {
+	return func(w *KeyValueWriter) {
+		if w.LevelIconSymbols == nil {
+			w.LevelIconSymbols = make(map[string]string, len(DefaultLevelIcons)+1)
+			for name, icon := range DefaultLevelIcons {
+				w.LevelIconSymbols[name] = icon
+			}
+		}
+		w.LevelIconSymbols[strings.ToLower(level)] = icon
+	}
+}
+
+// WithLevelFormatter sets the formatter applied to the level field.
+func WithLevelFormatter(f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatLevel = f
+	}
+}
+
+// WithMessageFieldName sets the key holding the event message.
+func WithMessageFieldName(name string) Option {
+	return func(w *KeyValueWriter) {
+		w.MessageFieldName = name
+	}
+}
+
+// WithUnquotedMessage enables or disables writing the message value without quotes.
+func WithUnquotedMessage(unquoted bool) Option {
+	return func(w *KeyValueWriter) {
+		w.UnquotedMessage = unquoted
+	}
+}
+
+// WithMessageFormatter sets the formatter applied to the message field.
+func WithMessageFormatter(f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatMessage = f
+	}
+}
+
+// WithCallerFieldName sets the key holding the event caller.
+func WithCallerFieldName(name string) Option {
+	return func(w *KeyValueWriter) {
+		w.CallerFieldName = name
+	}
+}
+
+// WithCallerPathSegments sets the number of trailing caller path segments to keep.
+func WithCallerPathSegments(n int) Option {
+	return func(w *KeyValueWriter) {
+		w.CallerPathSegments = n
+	}
+}
+
+// WithCallerFormatter sets the formatter applied to the caller field.
+func WithCallerFormatter(f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatCaller = f
+	}
+}
+
+// WithColorize sets when the output is colorized.
+func WithColorize(mode ColorMode) Option {
+	return func(w *KeyValueWriter) {
+		w.Colorize = mode
+	}
+}
+
+// WithKeyColor sets the color of keys.
+func WithKeyColor(c Color) Option {
+	return func(w *KeyValueWriter) {
+		w.KeyColor = c
+	}
+}
+
+// WithValueColor sets the color of values.
+func WithValueColor(c Color) Option {
+	return func(w *KeyValueWriter) {
+		w.ValueColor = c
+	}
+}
+
+// WithLevelColor sets the color of the given level.
+func WithLevelColor(level string, c Color) Option {
+	return func(w *KeyValueWriter) {
+		if w.LevelColors == nil {
+			w.LevelColors = make(map[string]Color, len(DefaultLevelColors)+1)
+			for name, c := range DefaultLevelColors {
+				w.LevelColors[name] = c
+			}
+		}
+		w.LevelColors[strings.ToLower(level)] = c
+	}
+}
+
+// WithTheme sets the color theme.
+func WithTheme(t Theme) Option {
+	return func(w *KeyValueWriter) {
+		w.Theme = &t
+	}
+}
+
+// WithRedactValues appends rules masking parts of values.
+func WithRedactValues(rules ...RedactionRule) Option {
+	return func(w *KeyValueWriter) {
+		w.RedactValues = append(w.RedactValues, rules...)
+	}
+}
+
+// WithRedactKeys appends fields whose values are replaced with RedactMask.
+func WithRedactKeys(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.RedactKeys = append(w.RedactKeys, keys...)
+	}
+}
+
+// WithRedactMask sets the value replacing the values of RedactKeys.
+func WithRedactMask(mask string) Option {
+	return func(w *KeyValueWriter) {
+		w.RedactMask = mask
+	}
+}
+
+// WithHashKeys appends fields whose values are replaced with their salted hash.
+func WithHashKeys(salt string, keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.HashSalt = salt
+		w.HashKeys = append(w.HashKeys, keys...)
+	}
+}
+
+// WithHashLength sets the number of hex digits of the hash written for HashKeys.
+func WithHashLength(n int) Option {
+	return func(w *KeyValueWriter) {
+		w.HashLength = n
+	}
+}
+
+// WithKeyFormatter sets the formatter applied to every key.
+func WithKeyFormatter(f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatKey = f
+	}
+}
+
+// WithDurationKeys renders numeric values of keys, or glob patterns, as human readable
+// durations in unit.
+func WithDurationKeys(unit time.Duration, keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		if w.DurationKeys == nil {
+			w.DurationKeys = make(map[string]time.Duration, len(keys))
+		}
+		for _, k := range keys {
+			w.DurationKeys[k] = unit
+		}
+	}
+}
+
+// WithDurationPrecision sets the maximum number of decimal places of humanized durations.
+func WithDurationPrecision(n int) Option {
+	return func(w *KeyValueWriter) {
+		w.DurationPrecision = n
+	}
+}
+
+// WithByteSizeKeys renders numeric values of keys, or glob patterns, as human readable
+// byte sizes.
+func WithByteSizeKeys(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.ByteSizeKeys = append(w.ByteSizeKeys, keys...)
+	}
+}
+
+// WithByteUnits sets the units of humanized byte sizes.
+func WithByteUnits(u ByteUnits) Option {
+	return func(w *KeyValueWriter) {
+		w.ByteUnits = u
+	}
+}
+
+// WithEpochKeys renders numeric values of keys, or glob patterns, as timestamps.
+func WithEpochKeys(keys ...string) Option {
+	return func(w *KeyValueWriter) {
+		w.EpochKeys = append(w.EpochKeys, keys...)
+	}
+}
+
+// WithDetectEpochs enables or disables rendering integers that look like epoch timestamps
+// as timestamps.
+func WithDetectEpochs(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.DetectEpochs = enabled
+	}
+}
+
+// WithUnitFormatter registers a formatter for values of keys ending with suffix.
+func WithUnitFormatter(suffix string, f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		if w.UnitFormatters == nil {
+			w.UnitFormatters = make(map[string]Formatter)
+		}
+		w.UnitFormatters[suffix] = f
+	}
+}
+
+// WithDefaultUnitFormatters registers the DefaultUnitFormatters.
+func WithDefaultUnitFormatters() Option {
+	return func(w *KeyValueWriter) {
+		if w.UnitFormatters == nil {
+			w.UnitFormatters = make(map[string]Formatter)
+		}
+		for suffix, f := range DefaultUnitFormatters() {
+			w.UnitFormatters[suffix] = f
+		}
+	}
+}
+
+// WithWebUnitFormatters registers the WebUnitFormatters.
+func WithWebUnitFormatters() Option {
+	return func(w *KeyValueWriter) {
+		if w.UnitFormatters == nil {
+			w.UnitFormatters = make(map[string]Formatter)
+		}
+		for suffix, f := range WebUnitFormatters() {
+			w.UnitFormatters[suffix] = f
+		}
+	}
+}
+
+// WithBoolFormat sets how booleans are rendered.
+func WithBoolFormat(f BoolFormat) Option {
+	return func(w *KeyValueWriter) {
+		w.BoolFormat = f
+	}
+}
+
+// WithNullMode sets how null values are rendered.
+func WithNullMode(m NullMode) Option {
+	return func(w *KeyValueWriter) {
+		w.NullMode = m
+	}
+}
+
+// WithNullPlaceholder writes null values as text.
+func WithNullPlaceholder(text string) Option {
+	return func(w *KeyValueWriter) {
+		w.NullMode = NullPlaceholder
+		w.NullText = text
+	}
+}
+
+// WithOmitEmpty enables or disables dropping keys with empty values.
+func WithOmitEmpty(enabled bool) Option {
+	return func(w *KeyValueWriter) {
+		w.OmitEmpty = enabled
+	}
+}
+
+// WithNumberFormat sets how numbers are rendered by the default value formatter.
+func WithNumberFormat(f NumberFormat) Option {
+	return func(w *KeyValueWriter) {
+		w.NumberFormat = f
+	}
+}
+
+// WithValueFormatter sets the formatter applied to every value.
+func WithValueFormatter(f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatValue = f
+	}
+}
+
+// WithFieldValueFormatter registers a value formatter for the given flattened key.
+func WithFieldValueFormatter(key string, f Formatter) Option {
+	return func(w *KeyValueWriter) {
+		if w.FormatFieldValue == nil {
+			w.FormatFieldValue = make(map[string]Formatter)
+		}
+		w.FormatFieldValue[key] = f
+	}
+}
+
+// WithExtraFormatter sets the function that can append extra output after the pairs.
+func WithExtraFormatter(f func(map[string]interface{}, *bytes.Buffer) error) Option {
+	return func(w *KeyValueWriter) {
+		w.FormatExtra = f
+	}
+}
+
+// WithHooks sets the hooks called before formatting and after writing events.
+func WithHooks(h Hooks) Option {
+	return func(w *KeyValueWriter) {
+		w.Hooks = h
+	}
+}
@@ -0,0 +1,65 @@
+package kvwriter
+
+import "sort"
+
+// sortedKeys returns the displayed keys of evt ordered by sortKeys in a pooled slice, to
+// be returned by putKeys.
+func (w KeyValueWriter) sortedKeys(evt map[string]interface{}) *[]string {
+	var keys = getKeys()
+	for key := range evt {
+		if w.keepKey(key) {
+			*keys = append(*keys, key)
+		}
+	}
+	w.sortKeys(*keys)
+	return keys
+}
+
+// sortKeys orders keys so that keys listed in FieldsOrder come first, in that order,
+// followed by the remaining keys sorted by KeySort or alphabetically.
+func (w KeyValueWriter) sortKeys(keys []string) {
+	var less = w.KeySort
+	if less == nil {
+		less = func(a, b string) bool { return a < b }
+	}
+
+	if len(w.FieldsOrder) == 0 {
+		if w.KeySort == nil {
+			sort.Strings(keys)
+		} else {
+			sort.SliceStable(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+		}
+		return
+	}
+
+	var priority map[string]int
+	if w.filter != nil {
+		priority = w.filter.priority
+	} else {
+		priority = fieldsPriority(w.FieldsOrder)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		pi, iok := priority[keys[i]]
+		pj, jok := priority[keys[j]]
+		switch {
+		case iok && jok:
+			return pi < pj
+		case iok != jok:
+			return iok
+		default:
+			return less(keys[i], keys[j])
+		}
+	})
+}
+
+// fieldsPriority maps the keys of order to their first position.
+func fieldsPriority(order []string) map[string]int {
+	var priority = make(map[string]int, len(order))
+	for i, key := range order {
+		if _, ok := priority[key]; !ok {
+			priority[key] = i
+		}
+	}
+	return priority
+}
@@ -0,0 +1,27 @@
+package kvwriter
+
+// OutputFormat defines the format of the written events.
+type OutputFormat int
+
+const (
+	// OutputKeyValue writes every event as a line of key-value pairs.
+	OutputKeyValue OutputFormat = iota
+	// OutputJournal writes every event as an entry of the systemd Journal Export Format,
+	// so its fields can be imported into journald as native fields.
+	OutputJournal
+	// OutputJSON writes every event as a single-line JSON object with the keys in the
+	// order of the pairs, after filtering, renaming, redaction and value formatting, e.g.
+	// for machine consumers of the same pipeline.
+	OutputJSON
+	// OutputCSV writes the values of Columns of every event as a CSV record.
+	OutputCSV
+	// OutputTSV writes the values of Columns of every event as a record of tab-separated
+	// values, escaping tabs, newlines and backslashes with a backslash.
+	OutputTSV
+	// OutputTable writes the values of Columns of every event as a row of aligned columns
+	// below a header, sized to the first TableRows events or declared by ColumnWidths.
+	OutputTable
+	// OutputValues writes only the values of Columns of every event, separated by the
+	// pairs delimiter, e.g. "15:04:05 INFO GET /api 200", for compact human output.
+	OutputValues
+)
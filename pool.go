@@ -0,0 +1,92 @@
+package kvwriter
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Pooled event maps and key slices larger than these caps are left to the garbage
+// collector, so a burst of huge events does not pin their memory.
+const (
+	maxPooledEventSize = 256
+	maxPooledKeys      = 256
+
+	// defaultMaxBufferSize is the default MaxBufferSize.
+	defaultMaxBufferSize = 64 << 10
+)
+
+var (
+	bufPool = sync.Pool{
+		New: func() interface{} {
+			return bytes.NewBuffer(make([]byte, 0, 100))
+		},
+	}
+	eventPool = sync.Pool{
+		New: func() interface{} {
+			return make(map[string]interface{}, 16)
+		},
+	}
+	keysPool = sync.Pool{
+		New: func() interface{} {
+			var keys = make([]string, 0, 16)
+			return &keys
+		},
+	}
+)
+
+// getBuffer returns an empty buffer from the pool that holds n bytes without growing,
+// e.g. the lines formatted from n bytes of input.
+func getBuffer(n int) *bytes.Buffer {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Grow(n)
+	return buf
+}
+
+// putBuffer returns the buffer to the pool, unless its capacity exceeds max and max is not
+// zero.
+func putBuffer(buf *bytes.Buffer, max int) {
+	if max > 0 && buf.Cap() > max {
+		return
+	}
+	buf.Reset()
+	bufPool.Put(buf)
+}
+
+// getEvent returns an empty event map from the pool.
+func getEvent() map[string]interface{} {
+	return eventPool.Get().(map[string]interface{})
+}
+
+// releaseEvents returns the events decoded by decode to the pool after rendering them.
+// The events of a Decoder are owned by it and are not pooled.
+func (w KeyValueWriter) releaseEvents(events []map[string]interface{}) {
+	if w.Decoder == nil {
+		putEvents(events)
+	}
+}
+
+// putEvents returns the decoded events to the pool. The events must not be used after.
+func putEvents(events []map[string]interface{}) {
+	for _, evt := range events {
+		if evt == nil || len(evt) > maxPooledEventSize {
+			continue
+		}
+		clear(evt)
+		eventPool.Put(evt)
+	}
+}
+
+// getKeys returns an empty key slice from the pool.
+func getKeys() *[]string {
+	return keysPool.Get().(*[]string)
+}
+
+// putKeys returns the key slice to the pool.
+func putKeys(keys *[]string) {
+	if cap(*keys) > maxPooledKeys {
+		return
+	}
+	clear(*keys)
+	*keys = (*keys)[:0]
+	keysPool.Put(keys)
+}
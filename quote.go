@@ -0,0 +1,175 @@
+package kvwriter
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// QuoteMode defines which values are quoted.
+type QuoteMode int
+
+const (
+	// QuoteDefault quotes all values if QuoteValues is enabled, or none.
+	QuoteDefault QuoteMode = iota
+	// QuoteAuto quotes only values that cannot be read back unquoted: empty values and
+	// values containing a delimiter, quotes, backslashes, whitespace or non-printable
+	// characters. Numbers, booleans and identifiers stay unquoted.
+	QuoteAuto
+)
+
+// quote returns the value of key quoted according to the writer configuration.
+func (w KeyValueWriter) quote(key, v string) string {
+	if w.LogfmtMode {
+		return logfmtValue(v)
+	}
+	if !w.quoteField(key) || w.QuoteMode == QuoteAuto && !w.needsQuote(v) {
+		return v
+	}
+	return quoteString(v, w.quoteChar())
+}
+
+// needsQuote reports whether v cannot be read back unquoted.
+func (w KeyValueWriter) needsQuote(v string) bool {
+	if v == "" || strings.Contains(v, w.pairsDelimiter()) || strings.Contains(v, w.keyValueDelimiter()) {
+		return true
+	}
+	var q = w.quoteChar()
+	for i := 0; i < len(v); {
+		r, n := utf8.DecodeRuneInString(v[i:])
+		if r == '"' || r == q || r == '\\' || unicode.IsSpace(r) || !strconv.IsPrint(r) ||
+			r == utf8.RuneError && n == 1 {
+			return true
+		}
+		i += n
+	}
+	return false
+}
+
+// quoteKey quotes k with QuoteKeys, or when it contains a delimiter, a quote, a backslash
+// or a non-printable character, so that the key can always be read back unambiguously.
+func (w KeyValueWriter) quoteKey(k string) string {
+	if w.LogfmtMode {
+		return logfmtKey(k)
+	}
+	var q = w.quoteChar()
+	if w.QuoteKeys || strings.Contains(k, w.pairsDelimiter()) || strings.Contains(k, w.keyValueDelimiter()) {
+		return quoteString(k, q)
+	}
+	for _, r := range k {
+		if r == '"' || r == q || r == '\\' || !strconv.IsPrint(r) {
+			return quoteString(k, q)
+		}
+	}
+	return k
+}
+
+// quoteChar returns the character quoting keys and values.
+func (w KeyValueWriter) quoteChar() rune {
+	if w.QuoteChar == 0 {
+		return '"'
+	}
+	return w.QuoteChar
+}
+
+// quoteString quotes s with q like strconv.Quote, escaping q instead of '"'.
+func quoteString(s string, q rune) string {
+	if q == '"' {
+		return strconv.Quote(s)
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteRune(q)
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == q || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == utf8.RuneError && n == 1 || !strconv.IsPrint(r):
+			e := strconv.Quote(s[i : i+n])
+			b.WriteString(e[1 : len(e)-1])
+		default:
+			b.WriteString(s[i : i+n])
+		}
+		i += n
+	}
+	b.WriteRune(q)
+	return b.String()
+}
+
+// logfmtValue returns v as a valid logfmt value, quoting it only when it contains
+// spaces, '=', quotes, control characters or invalid UTF-8.
+func logfmtValue(v string) string {
+	if !logfmtNeedsQuote(v) {
+		return v
+	}
+
+	var b strings.Builder
+	b.Grow(len(v) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(v); {
+		r, n := utf8.DecodeRuneInString(v[i:])
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError && n == 1:
+			b.WriteString("\ufffd")
+		case r < ' ' || r == 0x7f:
+			b.WriteString(`\u00`)
+			b.WriteByte(hexDigits[r>>4])
+			b.WriteByte(hexDigits[r&0xf])
+		default:
+			b.WriteString(v[i : i+n])
+		}
+		i += n
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func logfmtNeedsQuote(v string) bool {
+	for i := 0; i < len(v); {
+		r, n := utf8.DecodeRuneInString(v[i:])
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f || (r == utf8.RuneError && n == 1) {
+			return true
+		}
+		i += n
+	}
+	return false
+}
+
+// logfmtKey returns k as a valid logfmt key by replacing spaces, '=', quotes, control
+// characters and invalid UTF-8 with '_'.
+func logfmtKey(k string) string {
+	if k == "" {
+		return "_"
+	}
+	if !logfmtNeedsQuote(k) {
+		return k
+	}
+
+	var b strings.Builder
+	b.Grow(len(k))
+	for i := 0; i < len(k); {
+		r, n := utf8.DecodeRuneInString(k[i:])
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f || (r == utf8.RuneError && n == 1) {
+			b.WriteByte('_')
+		} else {
+			b.WriteString(k[i : i+n])
+		}
+		i += n
+	}
+	return b.String()
+}
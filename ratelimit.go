@@ -0,0 +1,181 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SuppressedFieldName is the key holding the number of events suppressed by the rate
+// limit in summary events.
+const SuppressedFieldName = "suppressed"
+
+// rateWindow counts the events of a single rate limited value in the current period.
+type rateWindow struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// rateLimitPeriod returns RateLimitPeriod, or a second if not set.
+func (w KeyValueWriter) rateLimitPeriod() time.Duration {
+	if w.RateLimitPeriod <= 0 {
+		return time.Second
+	}
+	return w.RateLimitPeriod
+}
+
+// rateLimit reports whether the event is within RateLimit. If the period of its value
+// ended with suppressed events, the summary of that period is returned and a new period
+// started.
+func (w KeyValueWriter) rateLimit(evt map[string]interface{}) (bool, map[string]interface{}) {
+	value, ok := evt[w.RateLimitKey]
+	if !ok || w.state == nil {
+		return true, nil
+	}
+	var id string
+	switch v := value.(type) {
+	case string:
+		id = v
+	case json.Number:
+		id = v.String()
+	default:
+		id = fmt.Sprint(v)
+	}
+
+	period := w.rateLimitPeriod()
+	now := time.Now()
+
+	s := w.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Ended periods without suppressed events are removed here, the others by the
+	// summaries written by the timer.
+	if now.Sub(s.rateSwept) >= period {
+		s.rateSwept = now
+		for k, win := range s.rateWindows {
+			if win.suppressed == 0 && now.Sub(win.start) >= period {
+				delete(s.rateWindows, k)
+			}
+		}
+	}
+
+	var summary map[string]interface{}
+	win, ok := s.rateWindows[id]
+	if ok && now.Sub(win.start) >= period {
+		if win.suppressed > 0 {
+			summary = w.suppressedEvent(id, win.suppressed)
+		}
+		*win = rateWindow{start: now}
+	} else if !ok {
+		if s.rateWindows == nil {
+			s.rateWindows = make(map[string]*rateWindow)
+		}
+		win = &rateWindow{start: now}
+		s.rateWindows[id] = win
+	}
+
+	win.count++
+	if win.count <= w.RateLimit {
+		return true, summary
+	}
+	win.suppressed++
+	if end := win.start.Add(period); s.rateTimer == nil || end.Before(s.rateTimerAt) {
+		w.armRateTimer(end.Sub(now))
+		s.rateTimerAt = end
+	}
+	return false, summary
+}
+
+// armRateTimer starts or resets the timer writing the summaries of ended periods after d.
+// The caller holds mu.
+func (w KeyValueWriter) armRateTimer(d time.Duration) {
+	s := w.state
+	if s.rateTimer != nil {
+		s.rateTimer.Reset(d)
+		return
+	}
+	s.rateTimer = time.AfterFunc(d, func() {
+		_ = w.writeRateSummaries(false)
+	})
+}
+
+// writeRateSummaries writes the summaries of the rate limited values whose period ended
+// with suppressed events, or of all values with suppressed events if all is set, e.g. by
+// Close. The timer is armed for the end of the next period with suppressed events.
+func (w KeyValueWriter) writeRateSummaries(all bool) error {
+	s := w.state
+	period := w.rateLimitPeriod()
+	now := time.Now()
+
+	s.mu.Lock()
+	if s.rateTimer != nil {
+		s.rateTimer.Stop()
+		s.rateTimer = nil
+	}
+	var ended []string
+	var next time.Time
+	for k, win := range s.rateWindows {
+		if win.suppressed == 0 {
+			continue
+		}
+		end := win.start.Add(period)
+		if all || !now.Before(end) {
+			ended = append(ended, k)
+		} else if next.IsZero() || end.Before(next) {
+			next = end
+		}
+	}
+	sort.Strings(ended)
+	var summaries []map[string]interface{}
+	for _, k := range ended {
+		summaries = append(summaries, w.suppressedEvent(k, s.rateWindows[k].suppressed))
+		delete(s.rateWindows, k)
+	}
+	if !next.IsZero() {
+		w.armRateTimer(next.Sub(now))
+		s.rateTimerAt = next
+	}
+	s.mu.Unlock()
+
+	if len(summaries) == 0 {
+		return nil
+	}
+	var buf = getBuffer(0)
+	defer putBuffer(buf, w.MaxBufferSize)
+	if err := w.renderSummaries(summaries, buf); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return w.writeOut(buf)
+}
+
+// renderSummaries renders summary events of the rate limit, which are neither sampled nor
+// rate limited themselves.
+func (w KeyValueWriter) renderSummaries(summaries []map[string]interface{}, buf *bytes.Buffer) error {
+	var sw = w
+	sw.RateLimit = 0
+	sw.Sampler = nil
+	for _, summary := range summaries {
+		ok, err := sw.renderEvent(summary, buf)
+		if err != nil {
+			return err
+		}
+		sw.countEvent(ok)
+	}
+	return nil
+}
+
+// suppressedEvent creates the summary event of the rate limited value id.
+func (w KeyValueWriter) suppressedEvent(id string, n int) map[string]interface{} {
+	return map[string]interface{}{
+		w.RateLimitKey:      id,
+		SuppressedFieldName: json.Number(strconv.Itoa(n)),
+	}
+}
@@ -0,0 +1,82 @@
+package kvwriter
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the writes of the rate limit timer.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRateLimitSummaries(t *testing.T) {
+	dropAll := SamplerFunc(func(map[string]interface{}, Level) bool { return false })
+
+	t.Run("timer", func(t *testing.T) {
+		var out syncBuffer
+		w := NewKeyValueWriter(WithOutput(&out), WithLocking(true), WithSampler(dropAll),
+			WithRateLimit("message", 1, 20*time.Millisecond))
+		for i := 0; i < 3; i++ {
+			if _, err := w.Write([]byte(`{"level":"error","message":"boom"}`)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		const want = `message="boom" suppressed="2"` + "\n"
+		deadline := time.Now().Add(time.Second)
+		for !strings.HasSuffix(out.String(), want) && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := out.String(); got != `level="error" message="boom"`+"\n"+want {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var out syncBuffer
+		w := NewKeyValueWriter(WithOutput(&out), WithRateLimit("message", 1, time.Hour))
+		for i := 0; i < 2; i++ {
+			if _, err := w.Write([]byte(`{"message":"boom"}`)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := out.String(), "message=\"boom\"\nmessage=\"boom\" suppressed=\"1\"\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("new period", func(t *testing.T) {
+		var out syncBuffer
+		w := NewKeyValueWriter(WithOutput(&out), WithRateLimit("message", 1, 20*time.Millisecond))
+		if _, err := w.Write([]byte(`{"message":"boom"}`)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if _, err := w.Write([]byte(`{"message":"boom"}`)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := out.String(), "message=\"boom\"\nmessage=\"boom\"\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package kvwriter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedactionRule replaces the parts of values matching Pattern with Replacement.
+// Replacement may refer to submatches, see regexp.Regexp.ReplaceAllString.
+type RedactionRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Built-in redaction rules.
+var (
+	// RedactCreditCards masks credit card numbers, optionally separated by spaces or dashes.
+	RedactCreditCards = RedactionRule{
+		Pattern:     regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Replacement: "***",
+	}
+
+	// RedactBearerTokens masks the credentials of bearer authorization values.
+	RedactBearerTokens = RedactionRule{
+		Pattern:     regexp.MustCompile(`(?i)\b(bearer)\s+[a-z0-9\-._~+/]+=*`),
+		Replacement: "$1 ***",
+	}
+)
+
+// NewRedactionRule compiles pattern into a rule replacing its matches with replacement.
+func NewRedactionRule(pattern, replacement string) (RedactionRule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RedactionRule{}, err
+	}
+	return RedactionRule{Pattern: re, Replacement: replacement}, nil
+}
+
+// redactValues applies RedactValues to all string and number values of obj.
+func (w KeyValueWriter) redactValues(obj map[string]interface{}) {
+	for k, v := range obj {
+		obj[k] = w.redactValue(v)
+	}
+}
+
+func (w KeyValueWriter) redactValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		w.redactValues(vv)
+	case []interface{}:
+		for i, elem := range vv {
+			vv[i] = w.redactValue(elem)
+		}
+	case string:
+		return w.redactString(vv)
+	case json.Number:
+		if s := w.redactString(vv.String()); s != vv.String() {
+			return s
+		}
+	}
+	return v
+}
+
+func (w KeyValueWriter) redactString(s string) string {
+	for _, rule := range w.RedactValues {
+		s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+	}
+	return s
+}
+
+// maskKeys replaces the values of the fields in obj matching RedactKeys with RedactMask
+// and the values of the fields matching HashKeys with their hash. parent is the flattened
+// key of obj, empty for the event itself.
+func (w KeyValueWriter) maskKeys(obj map[string]interface{}, parent string) {
+	for name, v := range obj {
+		path := w.joinKey(parent, name)
+		switch {
+		case matchField(w.RedactKeys, path, name):
+			obj[name] = w.RedactMask
+		case matchField(w.HashKeys, path, name):
+			obj[name] = w.hashValue(v)
+		default:
+			w.maskNestedKeys(v, path)
+		}
+	}
+}
+
+func (w KeyValueWriter) maskNestedKeys(v interface{}, path string) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		w.maskKeys(vv, path)
+	case []interface{}:
+		for i, elem := range vv {
+			w.maskNestedKeys(elem, w.joinKey(path, strconv.Itoa(i)))
+		}
+	}
+}
+
+// hashValue returns the first HashLength hex digits of the HMAC-SHA256 of v keyed with
+// HashSalt. Strings and numbers are hashed as written, other values as compact JSON.
+func (w KeyValueWriter) hashValue(v interface{}) string {
+	mac := hmac.New(sha256.New, []byte(w.HashSalt))
+	switch vv := v.(type) {
+	case string:
+		mac.Write([]byte(vv))
+	case json.Number:
+		mac.Write([]byte(vv))
+	default:
+		b, _ := json.Marshal(vv)
+		mac.Write(b)
+	}
+
+	sum := hex.EncodeToString(mac.Sum(nil))
+	if w.HashLength > 0 && w.HashLength < len(sum) {
+		sum = sum[:w.HashLength]
+	}
+	return sum
+}
+
+// matchField reports whether the field with the flattened key path and the given name
+// matches any of the patterns, ignoring case.
+func matchField(patterns []string, path, name string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	path, name = strings.ToLower(path), strings.ToLower(name)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if matchGlob(p, path) || matchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package kvwriter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// LevelRouter writes the lines formatted by Writer to different writers by the level of
+// their events, e.g. warnings and errors to stderr and errors additionally to a file
+// using io.MultiWriter. Events of levels without a route and without a known level are
+// written to Writer.Out. Batching is shared by all routes and should not be used.
+type LevelRouter struct {
+	Writer KeyValueWriter
+	Routes map[Level]io.Writer
+}
+
+// NewLevelRouter creates a LevelRouter formatting events with w.
+func NewLevelRouter(w KeyValueWriter, routes map[Level]io.Writer) LevelRouter {
+	return LevelRouter{Writer: w, Routes: routes}
+}
+
+// Write decodes p and writes every event to the writer of its level.
+func (r LevelRouter) Write(p []byte) (int, error) {
+	var buf = getBuffer(len(p))
+	defer putBuffer(buf, r.Writer.MaxBufferSize)
+
+	events, err := r.Writer.decode(p)
+	if err != nil {
+		return r.Writer.decodeError(p, err, buf)
+	}
+	defer r.Writer.releaseEvents(events)
+
+	var errs []error
+	for _, evt := range events {
+		if err := r.writeEvent(evt, buf); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(p), errors.Join(errs...)
+}
+
+// WriteEvent writes the event to the writer of its level like KeyValueWriter.WriteEvent.
+func (r LevelRouter) WriteEvent(evt map[string]interface{}) error {
+	var buf = getBuffer(0)
+	defer putBuffer(buf, r.Writer.MaxBufferSize)
+
+	normalizeMap(evt)
+	return r.writeEvent(evt, buf)
+}
+
+func (r LevelRouter) writeEvent(evt map[string]interface{}, buf *bytes.Buffer) error {
+	var w = r.Writer
+	if out, ok := r.Routes[w.EventLevel(evt)]; ok && out != nil {
+		w.Out = out
+	}
+
+	ok, err := w.renderEvent(evt, buf)
+	if err != nil {
+		return err
+	}
+	w.countEvent(ok)
+	if buf.Len() == 0 {
+		return nil
+	}
+	return w.writeOut(buf)
+}
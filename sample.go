@@ -0,0 +1,104 @@
+package kvwriter
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler decides whether an event is written. Events of LevelError and above are always
+// written without consulting the sampler. Samplers must be safe for concurrent use.
+type Sampler interface {
+	// Sample reports whether the event with the given level is written. The level is
+	// LevelUnset if the event has no known level.
+	Sample(evt map[string]interface{}, level Level) bool
+}
+
+// SamplerFunc is an adapter to use an ordinary function as a Sampler.
+type SamplerFunc func(evt map[string]interface{}, level Level) bool
+
+// Sample calls f(evt, level).
+func (f SamplerFunc) Sample(evt map[string]interface{}, level Level) bool {
+	return f(evt, level)
+}
+
+// EveryNthSampler writes the first and then every n-th event.
+type EveryNthSampler struct {
+	n       uint64
+	counter uint64
+}
+
+// NewEveryNthSampler creates a sampler writing every n-th event. If n is not positive,
+// all events are written.
+func NewEveryNthSampler(n int) *EveryNthSampler {
+	if n < 1 {
+		n = 1
+	}
+	return &EveryNthSampler{n: uint64(n)}
+}
+
+// Sample implements Sampler.
+func (s *EveryNthSampler) Sample(map[string]interface{}, Level) bool {
+	return (atomic.AddUint64(&s.counter, 1)-1)%s.n == 0
+}
+
+// RandomSampler writes events with a fixed probability.
+type RandomSampler struct {
+	p float64
+}
+
+// NewRandomSampler creates a sampler writing events with the probability p between 0 and
+// 1.
+func NewRandomSampler(p float64) RandomSampler {
+	return RandomSampler{p: p}
+}
+
+// Sample implements Sampler.
+func (s RandomSampler) Sample(map[string]interface{}, Level) bool {
+	return rand.Float64() < s.p
+}
+
+// BurstSampler writes up to burst events per level in every period and drops the rest.
+type BurstSampler struct {
+	burst  int
+	period time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	windows map[Level]*burstWindow
+}
+
+type burstWindow struct {
+	start time.Time
+	count int
+}
+
+// NewBurstSampler creates a sampler writing up to burst events per level in every period.
+func NewBurstSampler(burst int, period time.Duration) *BurstSampler {
+	return &BurstSampler{
+		burst:   burst,
+		period:  period,
+		now:     time.Now,
+		windows: make(map[Level]*burstWindow),
+	}
+}
+
+// Sample implements Sampler.
+func (s *BurstSampler) Sample(_ map[string]interface{}, level Level) bool {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	win, ok := s.windows[level]
+	if !ok {
+		win = &burstWindow{}
+		s.windows[level] = win
+	}
+	if now.Sub(win.start) >= s.period {
+		win.start, win.count = now, 0
+	}
+	win.count++
+	return win.count <= s.burst
+}
@@ -0,0 +1,193 @@
+package kvwriter
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// SlogHandler is a slog.Handler writing records through a KeyValueWriter. The record time,
+// level, message and source are written under the TimestampFieldName, LevelFieldName,
+// MessageFieldName and CallerFieldName of the writer, and groups become nested objects that
+// are flattened like any other event, e.g. 'request.method'.
+type SlogHandler struct {
+	w      KeyValueWriter
+	opts   slog.HandlerOptions
+	attrs  []slogGroupAttrs
+	groups []string
+}
+
+// slogGroupAttrs holds the attributes added by WithAttrs inside the groups open at the time.
+type slogGroupAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+// NewSlogHandler creates a slog.Handler writing through w. If opts is nil, the default
+// options are used.
+func NewSlogHandler(w KeyValueWriter, opts *slog.HandlerOptions) *SlogHandler {
+	h := &SlogHandler{w: w}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	var min = slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+// Handle formats the record and writes it.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	var evt = make(map[string]interface{}, r.NumAttrs()+4)
+
+	if !r.Time.IsZero() {
+		h.addBuiltin(evt, h.w.TimestampFieldName, slog.Time(slog.TimeKey, r.Time))
+	}
+	h.addBuiltin(evt, h.w.LevelFieldName, slog.Any(slog.LevelKey, r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		h.addBuiltin(evt, h.w.CallerFieldName, slog.String(slog.SourceKey, frame.File+":"+strconv.Itoa(frame.Line)))
+	}
+	h.addBuiltin(evt, h.w.MessageFieldName, slog.String(slog.MessageKey, r.Message))
+
+	for _, ga := range h.attrs {
+		h.addAttrs(evt, ga.groups, ga.attrs)
+	}
+
+	var attrs = make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	h.addAttrs(evt, h.groups, attrs)
+
+	return h.w.WriteEvent(evt)
+}
+
+// WithAttrs returns a handler that includes the attributes in every record.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], slogGroupAttrs{groups: h.groups, attrs: attrs})
+	return &h2
+}
+
+// WithGroup returns a handler that nests all following attributes in the group.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// addBuiltin adds a built-in attribute under the given writer field name, unless
+// ReplaceAttr renamed or removed it.
+func (h *SlogHandler) addBuiltin(evt map[string]interface{}, name string, a slog.Attr) {
+	var key = a.Key
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(nil, a)
+		a.Value = a.Value.Resolve()
+		if a.Key == "" {
+			return
+		}
+	}
+	if a.Key == key && name != "" {
+		a.Key = name
+	}
+	evt[a.Key] = slogValue(a.Value)
+}
+
+// addAttrs adds attrs nested in groups to evt. Groups without attributes are omitted.
+func (h *SlogHandler) addAttrs(evt map[string]interface{}, groups []string, attrs []slog.Attr) {
+	var m = make(map[string]interface{}, len(attrs))
+	for _, a := range attrs {
+		h.addAttr(m, groups, a)
+	}
+	if len(m) == 0 {
+		return
+	}
+
+	var target = evt
+	for _, g := range groups {
+		sub, ok := target[g].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			target[g] = sub
+		}
+		target = sub
+	}
+	for k, v := range m {
+		target[k] = v
+	}
+}
+
+func (h *SlogHandler) addAttr(m map[string]interface{}, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = slogValue(a.Value)
+		return
+	}
+
+	var group = a.Value.Group()
+	if a.Key == "" { // Inline groups without a key
+		for _, ga := range group {
+			h.addAttr(m, groups, ga)
+		}
+		return
+	}
+
+	var sub = make(map[string]interface{}, len(group))
+	groups = append(groups[:len(groups):len(groups)], a.Key)
+	for _, ga := range group {
+		h.addAttr(sub, groups, ga)
+	}
+	if len(sub) > 0 {
+		m[a.Key] = sub
+	}
+}
+
+// slogValue converts the resolved value to the types produced by decoding JSON.
+func slogValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return json.Number(strconv.FormatInt(v.Int64(), 10))
+	case slog.KindUint64:
+		return json.Number(strconv.FormatUint(v.Uint64(), 10))
+	case slog.KindFloat64:
+		return json.Number(strconv.FormatFloat(v.Float64(), 'g', -1, 64))
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	default:
+		if l, ok := v.Any().(slog.Level); ok {
+			return l.String()
+		}
+		return normalizeValue(v.Any())
+	}
+}
@@ -0,0 +1,95 @@
+package kvwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// splitContinuations removes the keys of values written on continuation lines from keys
+// and returns them separately, to be written by writeContinuation after the pairs: the
+// strings of StackTraceKeys and, with JSONStringsIndent, strings holding JSON.
+func (w KeyValueWriter) splitContinuations(evt map[string]interface{}, keys []string) (rest, continued []string) {
+	if len(w.StackTraceKeys) == 0 && w.JSONStrings != JSONStringsIndent {
+		return keys, nil
+	}
+	rest = keys[:0]
+	for _, key := range keys {
+		if s, ok := evt[key].(string); ok && s != "" &&
+			(matchAny(w.StackTraceKeys, key) || w.JSONStrings == JSONStringsIndent && isJSONContainer(s)) {
+			continued = append(continued, key)
+			continue
+		}
+		rest = append(rest, key)
+	}
+	return rest, continued
+}
+
+// writeContinuation appends the key on a continuation line prefixed with indent, followed
+// by every line of the value indented by another StackTraceIndent. Stack traces have their
+// paths shortened, JSON is indented.
+func (w KeyValueWriter) writeContinuation(buf *bytes.Buffer, key, value string, fk Formatter, indent string) {
+	k := w.quoteKey(formatKey(fk, key)) + ":"
+	if w.colorEnabled() {
+		k = Colored(k, w.keyColor())
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(indent)
+	buf.WriteString(k)
+
+	var segments = w.StackTracePathSegments
+	if !matchAny(w.StackTraceKeys, key) {
+		var b bytes.Buffer
+		if err := json.Indent(&b, []byte(strings.TrimSpace(value)), "", "  "); err == nil {
+			value = b.String()
+		}
+		segments = 0
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(value, "\r\n"), "\n") {
+		buf.WriteByte('\n')
+		buf.WriteString(indent)
+		buf.WriteString(w.StackTraceIndent)
+		buf.WriteString(shortenFramePaths(strings.TrimSuffix(line, "\r"), segments))
+	}
+}
+
+// shortenFramePaths trims the absolute file paths in a stack trace line to their last n
+// segments like TrimCallerPath, keeping line numbers. Paths start with a slash or a drive
+// letter at the start of the line or after a space, parenthesis or double quote.
+func shortenFramePaths(line string, n int) string {
+	if n <= 0 {
+		return line
+	}
+
+	var b strings.Builder
+	var last int
+	for i := 0; i < len(line); i++ {
+		if i > 0 && !strings.ContainsRune(" \t(\"", rune(line[i-1])) {
+			continue
+		}
+		if line[i] != '/' && !isDrivePath(line[i:]) {
+			continue
+		}
+		end := strings.IndexAny(line[i:], " \t)\",")
+		if end < 0 {
+			end = len(line)
+		} else {
+			end += i
+		}
+		b.WriteString(line[last:i])
+		b.WriteString(TrimCallerPath(line[i:end], n))
+		last, i = end, end
+	}
+	if last == 0 {
+		return line
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// isDrivePath reports whether s starts with a Windows path like C:\.
+func isDrivePath(s string) bool {
+	return len(s) >= 3 && (s[0] >= 'A' && s[0] <= 'Z' || s[0] >= 'a' && s[0] <= 'z') &&
+		s[1] == ':' && (s[2] == '\\' || s[2] == '/')
+}
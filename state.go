@@ -0,0 +1,99 @@
+package kvwriter
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// writerState holds the state shared by all copies of a writer created by
+// NewKeyValueWriter. KeyValueWriter is used by value, so anything that has to survive
+// between Write calls lives here.
+type writerState struct {
+	mu sync.Mutex
+
+	// alignWidths holds the widest pair seen so far for every key.
+	alignWidths map[string]int
+
+	// rateWindows holds the rate limit periods of the values of RateLimitKey, swept for
+	// ended periods at most once per period since rateSwept. rateTimer writes the
+	// summaries of suppressed events at rateTimerAt, the end of the next such period.
+	rateWindows map[string]*rateWindow
+	rateSwept   time.Time
+	rateTimer   *time.Timer
+	rateTimerAt time.Time
+
+	// lastLine is the last line written with Dedup and repeated the number of times it
+	// was dropped since.
+	lastLine []byte
+	repeated int
+
+	// headerWritten is set once the header of ColumnsHeader is written.
+	headerWritten bool
+
+	// tableRows are the rows buffered by OutputTable until tableWidths are known.
+	tableRows   [][]tableCell
+	tableWidths []int
+
+	stats writerStats
+
+	// outMu serializes the writes to Out with Locking and guards the batch.
+	outMu sync.Mutex
+
+	// batch buffers the output with FlushSize or FlushInterval, flushed by flushTimer.
+	batch      bytes.Buffer
+	flushTimer *time.Timer
+}
+
+func newWriterState() *writerState {
+	return &writerState{
+		alignWidths: make(map[string]int),
+	}
+}
+
+// dedup drops the line written to buf since start if it equals the last line. Otherwise,
+// the number of dropped repetitions of the last line is written before it. It reports
+// false if the line was dropped.
+func (s *writerState) dedup(buf *bytes.Buffer, start int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line := buf.Bytes()[start:]
+	if s.lastLine != nil && bytes.Equal(line, s.lastLine) {
+		s.repeated++
+		buf.Truncate(start)
+		return false
+	}
+
+	s.lastLine = append(s.lastLine[:0], line...)
+	if s.repeated > 0 {
+		buf.Truncate(start)
+		buf.WriteString("(repeated " + strconv.Itoa(s.repeated) + " times)\n")
+		buf.Write(s.lastLine)
+		s.repeated = 0
+	}
+	return true
+}
+
+// header reports whether the header of ColumnsHeader has to be written, which is true
+// only for the first call.
+func (s *writerState) header() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	written := s.headerWritten
+	s.headerWritten = true
+	return !written
+}
+
+// endDedup ends the current run of Dedup and returns the number of its dropped
+// repetitions.
+func (s *writerState) endDedup() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.repeated
+	s.lastLine, s.repeated = nil, 0
+	return n
+}
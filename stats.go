@@ -0,0 +1,61 @@
+package kvwriter
+
+import "sync/atomic"
+
+// Stats reports the counters of a writer.
+type Stats struct {
+	// EventsWritten counts the events formatted by Write, WriteEvent and FormatEvent.
+	EventsWritten uint64
+
+	// EventsDropped counts the events dropped by filters, MinLevel, Sampler, RateLimit
+	// and Dedup.
+	EventsDropped uint64
+
+	// DecodeErrors counts the inputs of Write that could not be decoded.
+	DecodeErrors uint64
+
+	// BytesWritten counts the bytes written to Out.
+	BytesWritten uint64
+}
+
+// writerStats holds the counters reported by Stats.
+type writerStats struct {
+	eventsWritten atomic.Uint64
+	eventsDropped atomic.Uint64
+	decodeErrors  atomic.Uint64
+	bytesWritten  atomic.Uint64
+}
+
+// Stats returns the counters shared by all copies of a writer created by
+// NewKeyValueWriter. Writers created otherwise report zero counters.
+func (w KeyValueWriter) Stats() Stats {
+	if w.state == nil {
+		return Stats{}
+	}
+	s := &w.state.stats
+	return Stats{
+		EventsWritten: s.eventsWritten.Load(),
+		EventsDropped: s.eventsDropped.Load(),
+		DecodeErrors:  s.decodeErrors.Load(),
+		BytesWritten:  s.bytesWritten.Load(),
+	}
+}
+
+// countEvent counts a formatted or dropped event.
+func (w KeyValueWriter) countEvent(ok bool) {
+	if w.state == nil {
+		return
+	}
+	if ok {
+		w.state.stats.eventsWritten.Add(1)
+	} else {
+		w.state.stats.eventsDropped.Add(1)
+	}
+}
+
+// countDecodeError counts an input that could not be decoded.
+func (w KeyValueWriter) countDecodeError() {
+	if w.state != nil {
+		w.state.stats.decodeErrors.Add(1)
+	}
+}
@@ -0,0 +1,29 @@
+package kvwriter
+
+import (
+	"io"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	w := NewKeyValueWriter(WithOutput(io.Discard), WithMinLevel(LevelInfo), WithErrorMode(ErrorDrop))
+	for _, in := range []string{`{"level":"info","a":1}`, `{"level":"debug"}`, `not json`} {
+		if _, err := w.Write([]byte(in)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteEvent(map[string]interface{}{"level": "error"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Stats{EventsWritten: 2, EventsDropped: 1, DecodeErrors: 1, BytesWritten: uint64(len("level=\"info\" a=\"1\"\nlevel=\"error\"\n"))}
+	if got := w.With().Stats(); got != (Stats{}) {
+		t.Errorf("copy made by With shares the stats: %+v", got)
+	}
+	if got := w.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := (KeyValueWriter{}).Stats(); got != (Stats{}) {
+		t.Errorf("writer literal: got %+v", got)
+	}
+}
@@ -0,0 +1,184 @@
+package kvwriter
+
+import (
+	"bytes"
+	"sync"
+)
+
+// DefaultMaxBuffer is the default size limit of the StreamWriter buffer.
+const DefaultMaxBuffer = 1 << 20
+
+// StreamWriter buffers arbitrarily split writes and passes complete events to a
+// KeyValueWriter, so it can be used behind bufio, io.Copy or pipes. An event is a complete
+// JSON object or array, which may span several lines, or any other single line. A line
+// starting a JSON value that the next line cannot continue, e.g. a truncated event, is
+// written as a single line.
+// StreamWriter is safe for concurrent use.
+type StreamWriter struct {
+	// MaxBuffer limits the buffered bytes of an incomplete event. When exceeded, the
+	// buffer is written as an event as is. (default: DefaultMaxBuffer)
+	MaxBuffer int
+
+	w   KeyValueWriter
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewStreamWriter creates a StreamWriter writing the events through w.
+func NewStreamWriter(w KeyValueWriter) *StreamWriter {
+	return &StreamWriter{w: w, MaxBuffer: DefaultMaxBuffer}
+}
+
+// Write buffers p and writes all events completed by it. It returns the first error of
+// the written events, but always consumes all of p.
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = append(s.buf, p...)
+
+	var err error
+	for {
+		evt, rest, ok := nextEvent(s.buf)
+		if !ok {
+			break
+		}
+		if len(evt) > 0 {
+			if _, werr := s.w.Write(evt); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		s.buf = rest
+	}
+
+	if s.MaxBuffer > 0 && len(s.buf) > s.MaxBuffer {
+		if ferr := s.flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+
+	// Release the memory of written events.
+	if len(s.buf) == 0 {
+		s.buf = s.buf[:0:0]
+	}
+
+	return len(p), err
+}
+
+// Flush writes the buffered incomplete event, if any.
+func (s *StreamWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Close flushes the buffered incomplete event.
+func (s *StreamWriter) Close() error {
+	return s.Flush()
+}
+
+func (s *StreamWriter) flush() error {
+	var evt = bytes.TrimSpace(s.buf)
+	s.buf = nil
+	if len(evt) == 0 {
+		return nil
+	}
+	_, err := s.w.Write(evt)
+	return err
+}
+
+// nextEvent splits the first complete event off b. Leading whitespace is skipped. It
+// reports false if b does not contain a complete event yet.
+func nextEvent(b []byte) (evt, rest []byte, ok bool) {
+	var start int
+	for start < len(b) && isLogfmtSpace(b[start]) {
+		start++
+	}
+	if start == len(b) {
+		return nil, b[:0], start > 0
+	}
+
+	if b[start] == '{' || b[start] == '[' {
+		switch end := jsonValueEnd(b[start:]); {
+		case end > 0:
+			return b[start : start+end], b[start+end:], true
+		case end == 0:
+			return nil, b, false
+		}
+		// The first line cannot be continued, e.g. a truncated event, and is written as
+		// a line of its own.
+	}
+
+	if i := bytes.IndexByte(b[start:], '\n'); i >= 0 {
+		return bytes.TrimRight(b[start:start+i], "\r"), b[start+i+1:], true
+	}
+	return nil, b, false
+}
+
+// jsonValueEnd returns the length of the JSON object or array at the start of b, or 0 if
+// it is incomplete. Only brackets, strings and the first character of continuation lines
+// are checked, the value is validated when decoded. It returns -1 if the value is broken
+// at the end of a line, because a string spans the line or the next line cannot continue
+// the value, e.g. a truncated event followed by the next one.
+func jsonValueEnd(b []byte) int {
+	var open []byte // the brackets of the enclosing objects and arrays
+	var prev byte   // the last character outside strings and whitespace
+	var inString, escaped, lineStart bool
+	for i, c := range b {
+		if lineStart && !isLogfmtSpace(c) {
+			lineStart = false
+			if !continuesLine(open[len(open)-1], prev, c) {
+				return -1
+			}
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+				prev = c
+			case '\n':
+				return -1
+			}
+		case c == '\n':
+			lineStart = true
+		case isLogfmtSpace(c):
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			open = append(open, c)
+			prev = c
+		case c == '}' || c == ']':
+			open = open[:len(open)-1]
+			if len(open) == 0 {
+				return i + 1
+			}
+			prev = c
+		default:
+			prev = c
+		}
+	}
+	return 0
+}
+
+// continuesLine reports whether a line starting with c may continue a value of the object
+// or array opened by bracket after the character prev.
+func continuesLine(bracket, prev, c byte) bool {
+	var end byte = ']'
+	if bracket == '{' {
+		end = '}'
+	}
+	switch {
+	case c == end:
+		return true
+	case bracket == '{' && (prev == '{' || prev == ','):
+		return c == '"'
+	case prev == ':', bracket == '[' && (prev == '[' || prev == ','):
+		return true
+	}
+	return c == ','
+}
@@ -0,0 +1,31 @@
+package kvwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStreamWriterTruncatedLine(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"truncated string", "{\"msg\":\"cut\n{\"msg\":\"next\"}\n", "{\"msg\":\"cut\nmsg=\"next\"\n"},
+		{"truncated object", "{\"msg\":\"cut\",\n{\"msg\":\"next\"}\n", "{\"msg\":\"cut\",\nmsg=\"next\"\n"},
+		{"multiline object", "{\n  \"msg\": \"a\",\n  \"http\": {\n    \"status\": 200\n  },\n  \"tags\": [\n    1,\n    {\"b\": 2}\n  ]\n}\n", "http.status=\"200\" msg=\"a\" tags.0=\"1\" tags.1.b=\"2\"\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s := NewStreamWriter(NewKeyValueWriter(WithOutput(&out), WithErrorMode(ErrorPassThrough)))
+		for i := 0; i < len(tt.in); i++ {
+			if _, err := s.Write([]byte{tt.in[i]}); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
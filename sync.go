@@ -0,0 +1,25 @@
+package kvwriter
+
+import (
+	"io"
+	"sync"
+)
+
+// SyncWriter serializes the writes to an io.Writer shared by multiple writers or
+// goroutines, so every Write is written as a whole.
+type SyncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewSyncWriter creates a SyncWriter writing to w.
+func NewSyncWriter(w io.Writer) *SyncWriter {
+	return &SyncWriter{w: w}
+}
+
+// Write writes p to the underlying writer holding the lock.
+func (s *SyncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
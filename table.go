@@ -0,0 +1,189 @@
+package kvwriter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tableGap separates the columns of OutputTable.
+const tableGap = "  "
+
+// tableEscaper escapes the characters that would break the rows of OutputTable.
+var tableEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// tableCell is a formatted value of OutputTable and its color.
+type tableCell struct {
+	text  string
+	color Color
+}
+
+// validateTable reports whether the configuration of OutputTable is usable.
+func (w KeyValueWriter) validateTable() error {
+	if w.state == nil {
+		return errors.New("table output requires a writer created by NewKeyValueWriter")
+	}
+	if len(w.Columns) == 0 {
+		return errors.New("table output requires columns")
+	}
+	if len(w.ColumnWidths) > 0 {
+		if len(w.ColumnWidths) != len(w.Columns) {
+			return fmt.Errorf("%d column widths for %d columns", len(w.ColumnWidths), len(w.Columns))
+		}
+		for _, width := range w.ColumnWidths {
+			if width <= 0 {
+				return fmt.Errorf("invalid column width %d", width)
+			}
+		}
+	} else if w.TableRows <= 0 {
+		return fmt.Errorf("invalid table rows %d", w.TableRows)
+	}
+	return nil
+}
+
+// writeTable appends the event to buf as a row of OutputTable. Until the column widths
+// are known, the row is buffered and writeTable reports false. Once TableRows rows are
+// buffered, they are written with the header, sized to their widest values.
+func (w KeyValueWriter) writeTable(evt map[string]interface{}, buf *bytes.Buffer) bool {
+	row := w.tableRow(evt)
+
+	s := w.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tableWidths == nil {
+		if len(w.ColumnWidths) == 0 {
+			s.tableRows = append(s.tableRows, row)
+			if len(s.tableRows) < w.TableRows {
+				return false
+			}
+			w.writeTableRowsLocked(buf)
+			return true
+		}
+		s.tableWidths = w.ColumnWidths
+		w.writeTableHeader(buf, s.tableWidths)
+		buf.WriteByte('\n')
+	}
+	w.writeTableRow(buf, row, s.tableWidths)
+	return true
+}
+
+// flushTable writes the rows buffered by OutputTable.
+func (w KeyValueWriter) flushTable() error {
+	if w.OutputFormat != OutputTable || w.state == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w.state.mu.Lock()
+	if len(w.state.tableRows) > 0 {
+		w.writeTableRowsLocked(&buf)
+		buf.WriteByte('\n')
+	}
+	w.state.mu.Unlock()
+
+	if buf.Len() == 0 {
+		return nil
+	}
+	return w.writeOut(&buf)
+}
+
+// writeTableRowsLocked sizes the columns to the buffered rows and writes them with the
+// header. The caller holds the state mutex.
+func (w KeyValueWriter) writeTableRowsLocked(buf *bytes.Buffer) {
+	s := w.state
+	s.tableWidths = make([]int, len(w.Columns))
+	for i, col := range w.Columns {
+		s.tableWidths[i] = utf8.RuneCountInString(col)
+	}
+	for _, row := range s.tableRows {
+		for i, cell := range row {
+			s.tableWidths[i] = max(s.tableWidths[i], utf8.RuneCountInString(cell.text))
+		}
+	}
+
+	w.writeTableHeader(buf, s.tableWidths)
+	for _, row := range s.tableRows {
+		buf.WriteByte('\n')
+		w.writeTableRow(buf, row, s.tableWidths)
+	}
+	s.tableRows = nil
+}
+
+// tableRow formats the values of Columns of the event.
+func (w KeyValueWriter) tableRow(evt map[string]interface{}) []tableCell {
+	_, fv := w.formatters()
+	var color = w.colorEnabled()
+	var row = make([]tableCell, len(w.Columns))
+	for i, col := range w.Columns {
+		value, ok := evt[col]
+		if !ok || !w.keepKey(col) {
+			continue
+		}
+		s, _ := w.formatValue(col, value, fv)
+		row[i].text = tableEscaper.Replace(s)
+		if color {
+			row[i].color = w.valueColor(col, value)
+		}
+	}
+	return row
+}
+
+func (w KeyValueWriter) writeTableHeader(buf *bytes.Buffer, widths []int) {
+	var row = make([]tableCell, len(w.Columns))
+	for i, col := range w.Columns {
+		row[i].text = col
+		if w.colorEnabled() {
+			row[i].color = w.keyColor()
+		}
+	}
+	w.writeTableRow(buf, row, widths)
+}
+
+// writeTableRow appends the cells padded to the column widths. Longer values are cut and
+// Ellipsis is appended, except in the last column, which is neither padded nor cut.
+// Trailing empty cells are not padded.
+func (w KeyValueWriter) writeTableRow(buf *bytes.Buffer, row []tableCell, widths []int) {
+	var end = buf.Len()
+	for i, cell := range row {
+		if i > 0 {
+			buf.WriteString(tableGap)
+		}
+		text, n := cell.text, 0
+		if i < len(row)-1 {
+			text, n = w.fitCell(cell.text, widths[i])
+		}
+		buf.WriteString(Colored(text, cell.color))
+		if text != "" {
+			end = buf.Len()
+		}
+		if i < len(row)-1 {
+			buf.WriteString(strings.Repeat(" ", widths[i]-n))
+		}
+	}
+	buf.Truncate(end)
+}
+
+// fitCell cuts s to width runes, ending with Ellipsis if cut, and returns it with its
+// width.
+func (w KeyValueWriter) fitCell(s string, width int) (string, int) {
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s, n
+	}
+	var ellipsis = w.Ellipsis
+	if m := utf8.RuneCountInString(ellipsis); m < width {
+		width -= m
+	} else {
+		ellipsis = ""
+	}
+
+	var i int
+	for j := 0; j < width; j++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i] + ellipsis, utf8.RuneCountInString(s[:i] + ellipsis)
+}
@@ -0,0 +1,157 @@
+package kvwriter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// restMarker stands for the pairs written by the rest function of Template, replaced
+// after the template is executed.
+const restMarker = "\x00rest\x00"
+
+// templateFuncs are the functions available in Template.
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"pad": func(width int, s string) string {
+		if n := len([]rune(s)); n < width {
+			return s + strings.Repeat(" ", width-n)
+		}
+		return s
+	},
+	"rest": func() string {
+		return restMarker
+	},
+}
+
+// lineTemplate is Template compiled by NewKeyValueWriter and With, with the keys it
+// refers to.
+type lineTemplate struct {
+	from string
+	t    *template.Template
+	keys map[string]struct{}
+}
+
+// parseTemplate parses the line template text.
+func parseTemplate(text string) (*lineTemplate, error) {
+	t, err := template.New("line").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	lt := &lineTemplate{from: text, t: t, keys: make(map[string]struct{})}
+	lt.collectKeys(t.Root)
+	return lt, nil
+}
+
+// compileTemplate compiles Template. An invalid template is reported by Validate.
+func (w *KeyValueWriter) compileTemplate() {
+	w.template = nil
+	if w.Template != "" {
+		w.template, _ = parseTemplate(w.Template)
+	}
+}
+
+// lineTemplate returns the compiled Template, parsing it if it was changed since it was
+// compiled.
+func (w KeyValueWriter) lineTemplate() (*lineTemplate, error) {
+	if w.template != nil && w.template.from == w.Template {
+		return w.template, nil
+	}
+	return parseTemplate(w.Template)
+}
+
+// collectKeys collects the keys referred to by node, as .key or index . "key", which are
+// not written by rest.
+func (lt *lineTemplate) collectKeys(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			lt.collectKeys(child)
+		}
+	case *parse.ActionNode:
+		lt.collectKeys(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			lt.collectKeys(cmd)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 3 {
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok && id.Ident == "index" {
+				if _, ok := n.Args[1].(*parse.DotNode); ok {
+					if s, ok := n.Args[2].(*parse.StringNode); ok {
+						lt.keys[s.Text] = struct{}{}
+					}
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			lt.collectKeys(arg)
+		}
+	case *parse.FieldNode:
+		lt.keys[n.Ident[0]] = struct{}{}
+	case *parse.IfNode:
+		lt.collectBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		lt.collectBranch(&n.BranchNode)
+	case *parse.WithNode:
+		lt.collectBranch(&n.BranchNode)
+	}
+}
+
+func (lt *lineTemplate) collectBranch(n *parse.BranchNode) {
+	lt.collectKeys(n.Pipe)
+	lt.collectKeys(n.List)
+	lt.collectKeys(n.ElseList)
+}
+
+// writeTemplate appends the event formatted by Template to buf. The template is executed
+// with the formatted values of the kept keys, without quotes and colors, and rest writes
+// the pairs the template does not refer to.
+func (w KeyValueWriter) writeTemplate(evt map[string]interface{}, buf *bytes.Buffer) error {
+	lt, err := w.lineTemplate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+
+	_, fv := w.formatters()
+	var data = make(map[string]string, len(evt))
+	var rest = make(map[string]interface{})
+	for key, value := range evt {
+		if !w.keepKey(key) {
+			continue
+		}
+		data[key], _ = w.formatValue(key, value, fv)
+		if _, ok := lt.keys[key]; !ok {
+			rest[key] = value
+		}
+	}
+
+	var b strings.Builder
+	if err := lt.t.Execute(&b, data); err != nil {
+		return fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+
+	// Trailing spaces are removed, e.g. of a rest without pairs.
+	var start = buf.Len()
+	var s = b.String()
+	for {
+		before, after, found := strings.Cut(s, restMarker)
+		buf.WriteString(before)
+		if !found {
+			break
+		}
+		w.writePairs(rest, buf)
+		s = after
+	}
+	buf.Truncate(start + len(bytes.TrimRight(buf.Bytes()[start:], " ")))
+	return nil
+}
@@ -0,0 +1,168 @@
+package kvwriter
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// ColorDepth defines the color palette supported by the terminal.
+type ColorDepth int
+
+const (
+	// ColorDepthBasic uses the 16 basic ANSI colors.
+	ColorDepthBasic ColorDepth = iota
+	// ColorDepth256 uses the 256-color palette.
+	ColorDepth256
+	// ColorDepthTrueColor uses 24-bit RGB colors.
+	ColorDepthTrueColor
+)
+
+// Color256 returns the foreground color n of the 256-color palette.
+func Color256(n uint8) Color {
+	return Color("38;5;" + strconv.Itoa(int(n)))
+}
+
+// ColorRGB returns the 24-bit foreground color.
+func ColorRGB(r, g, b uint8) Color {
+	return Color("38;2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b)))
+}
+
+// Theme defines the colors of the colorized output.
+type Theme struct {
+	// Key is the color of keys.
+	Key Color
+
+	// String, Number, Bool and Null are the colors of values by their JSON type.
+	// Objects and arrays use String.
+	String Color
+	Number Color
+	Bool   Color
+	Null   Color
+
+	// Levels maps level names to the colors of level values. Names are matched
+	// case-insensitively and by their abbreviation.
+	Levels map[string]Color
+}
+
+// DarkTheme returns a theme for terminals with a dark background.
+func DarkTheme(depth ColorDepth) Theme {
+	switch depth {
+	case ColorDepth256:
+		return Theme{
+			Key:    Color256(244),
+			String: Color256(252),
+			Number: Color256(117),
+			Bool:   Color256(214),
+			Null:   Color256(240),
+			Levels: map[string]Color{
+				"trace": Color256(141),
+				"debug": Color256(75),
+				"info":  Color256(114),
+				"warn":  Color256(221),
+				"error": Color256(203),
+				"fatal": ColorBold + ";" + Color256(196),
+				"panic": ColorBold + ";" + Color256(196),
+			},
+		}
+	case ColorDepthTrueColor:
+		return Theme{
+			Key:    ColorRGB(128, 128, 128),
+			String: ColorRGB(220, 220, 220),
+			Number: ColorRGB(130, 200, 255),
+			Bool:   ColorRGB(255, 175, 0),
+			Null:   ColorRGB(88, 88, 88),
+			Levels: map[string]Color{
+				"trace": ColorRGB(175, 135, 255),
+				"debug": ColorRGB(95, 175, 255),
+				"info":  ColorRGB(135, 215, 135),
+				"warn":  ColorRGB(255, 215, 95),
+				"error": ColorRGB(255, 95, 95),
+				"fatal": ColorBold + ";" + ColorRGB(255, 0, 0),
+				"panic": ColorBold + ";" + ColorRGB(255, 0, 0),
+			},
+		}
+	default:
+		return Theme{
+			Key:    ColorDim,
+			Number: ColorCyan,
+			Bool:   ColorYellow,
+			Null:   ColorDim,
+			Levels: DefaultLevelColors,
+		}
+	}
+}
+
+// LightTheme returns a theme for terminals with a light background.
+func LightTheme(depth ColorDepth) Theme {
+	switch depth {
+	case ColorDepth256:
+		return Theme{
+			Key:    Color256(243),
+			String: Color256(235),
+			Number: Color256(25),
+			Bool:   Color256(130),
+			Null:   Color256(248),
+			Levels: map[string]Color{
+				"trace": Color256(91),
+				"debug": Color256(26),
+				"info":  Color256(28),
+				"warn":  Color256(136),
+				"error": Color256(160),
+				"fatal": ColorBold + ";" + Color256(124),
+				"panic": ColorBold + ";" + Color256(124),
+			},
+		}
+	case ColorDepthTrueColor:
+		return Theme{
+			Key:    ColorRGB(118, 118, 118),
+			String: ColorRGB(38, 38, 38),
+			Number: ColorRGB(0, 95, 175),
+			Bool:   ColorRGB(175, 95, 0),
+			Null:   ColorRGB(168, 168, 168),
+			Levels: map[string]Color{
+				"trace": ColorRGB(135, 0, 175),
+				"debug": ColorRGB(0, 95, 215),
+				"info":  ColorRGB(0, 135, 0),
+				"warn":  ColorRGB(175, 135, 0),
+				"error": ColorRGB(215, 0, 0),
+				"fatal": ColorBold + ";" + ColorRGB(175, 0, 0),
+				"panic": ColorBold + ";" + ColorRGB(175, 0, 0),
+			},
+		}
+	default:
+		return Theme{
+			Key:    ColorDim,
+			Number: ColorBlue,
+			Bool:   ColorMagenta,
+			Null:   ColorDim,
+			Levels: DefaultLevelColors,
+		}
+	}
+}
+
+// MonochromeTheme returns a theme using only text attributes, for terminals without colors.
+func MonochromeTheme() Theme {
+	return Theme{
+		Key: ColorDim,
+		Levels: map[string]Color{
+			"warn":  ColorBold,
+			"error": ColorBold,
+			"fatal": ColorBold,
+			"panic": ColorBold,
+		},
+	}
+}
+
+// colorOf returns the theme color of the JSON value.
+func (t *Theme) colorOf(value interface{}) Color {
+	switch value.(type) {
+	case nil:
+		return t.Null
+	case bool:
+		return t.Bool
+	case json.Number, float64:
+		return t.Number
+	default:
+		return t.String
+	}
+}
@@ -0,0 +1,88 @@
+package kvwriter
+
+// Transformer transforms events before they are formatted. It returns the transformed
+// event, which may be evt modified in place, and false to drop the event.
+type Transformer interface {
+	Transform(evt map[string]interface{}) (map[string]interface{}, bool)
+}
+
+// TransformerFunc is an adapter to use an ordinary function as a Transformer.
+type TransformerFunc func(evt map[string]interface{}) (map[string]interface{}, bool)
+
+// Transform calls f(evt).
+func (f TransformerFunc) Transform(evt map[string]interface{}) (map[string]interface{}, bool) {
+	return f(evt)
+}
+
+// Pipeline is a Transformer running its transformers in order, stopping at the first one
+// dropping the event.
+type Pipeline []Transformer
+
+// Transform implements Transformer.
+func (p Pipeline) Transform(evt map[string]interface{}) (map[string]interface{}, bool) {
+	for _, t := range p {
+		var ok bool
+		if evt, ok = t.Transform(evt); !ok {
+			return nil, false
+		}
+	}
+	return evt, true
+}
+
+// FilterTransformer returns a Transformer dropping the events for which keep returns
+// false.
+func FilterTransformer(keep func(evt map[string]interface{}) bool) Transformer {
+	return TransformerFunc(func(evt map[string]interface{}) (map[string]interface{}, bool) {
+		return evt, keep(evt)
+	})
+}
+
+// RenameTransformer returns a Transformer renaming the key from to to.
+func RenameTransformer(from, to string) Transformer {
+	return TransformerFunc(func(evt map[string]interface{}) (map[string]interface{}, bool) {
+		if v, ok := evt[from]; ok {
+			delete(evt, from)
+			evt[to] = v
+		}
+		return evt, true
+	})
+}
+
+// DropTransformer returns a Transformer deleting the keys matching any of the glob
+// patterns.
+func DropTransformer(patterns ...string) Transformer {
+	return TransformerFunc(func(evt map[string]interface{}) (map[string]interface{}, bool) {
+		for k := range evt {
+			if matchAny(patterns, k) {
+				delete(evt, k)
+			}
+		}
+		return evt, true
+	})
+}
+
+// EnrichTransformer returns a Transformer adding the fields to every event, keeping the
+// values already present.
+func EnrichTransformer(fields map[string]interface{}) Transformer {
+	return TransformerFunc(func(evt map[string]interface{}) (map[string]interface{}, bool) {
+		for k, v := range fields {
+			if _, ok := evt[k]; !ok {
+				evt[k] = v
+			}
+		}
+		return evt, true
+	})
+}
+
+// RedactTransformer returns a Transformer replacing the values of the keys matching any
+// of the glob patterns, ignoring case, with mask.
+func RedactTransformer(mask string, patterns ...string) Transformer {
+	return TransformerFunc(func(evt map[string]interface{}) (map[string]interface{}, bool) {
+		for k := range evt {
+			if matchField(patterns, k, k) {
+				evt[k] = mask
+			}
+		}
+		return evt, true
+	})
+}
@@ -0,0 +1,23 @@
+package kvwriter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFilterEventPipeline(t *testing.T) {
+	var out bytes.Buffer
+	w := NewKeyValueWriter(WithOutput(&out),
+		WithFilterEvent(func(evt map[string]interface{}) bool { return evt["http.status"] != "200" }),
+		WithTransformers(DropTransformer("http.*")))
+	if len(w.Pipeline) != 2 || w.FilterEvent != nil {
+		t.Fatalf("pipeline has %d stages", len(w.Pipeline))
+	}
+
+	if _, err := w.Write([]byte("{\"msg\":\"ok\",\"http\":{\"status\":\"200\"}}\n{\"msg\":\"missing\",\"http\":{\"status\":\"404\"}}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "msg=\"missing\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
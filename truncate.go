@@ -0,0 +1,30 @@
+package kvwriter
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// truncateValue shortens v to MaxValueLength runes and appends Ellipsis and, with
+// ShowTruncatedBytes, the number of removed bytes.
+func (w KeyValueWriter) truncateValue(v string) string {
+	if w.MaxValueLength <= 0 || len(v) <= w.MaxValueLength {
+		return v
+	}
+
+	var i, n int
+	for i < len(v) && n < w.MaxValueLength {
+		_, size := utf8.DecodeRuneInString(v[i:])
+		i += size
+		n++
+	}
+	if i == len(v) {
+		return v
+	}
+
+	var s = v[:i] + w.Ellipsis
+	if w.ShowTruncatedBytes {
+		s += "(+" + strconv.Itoa(len(v)-i) + " bytes)"
+	}
+	return s
+}
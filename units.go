@@ -0,0 +1,45 @@
+package kvwriter
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultUnitFormatters returns unit formatters for common key suffixes: durations (_ns,
+// _us, _ms, _sec, _seconds), byte sizes (_bytes) and percentages (_pct, _percent).
+func DefaultUnitFormatters() map[string]Formatter {
+	return map[string]Formatter{
+		"_ns":      DurationFormatter(time.Nanosecond, 2),
+		"_us":      DurationFormatter(time.Microsecond, 2),
+		"_ms":      DurationFormatter(time.Millisecond, 2),
+		"_sec":     DurationFormatter(time.Second, 2),
+		"_seconds": DurationFormatter(time.Second, 2),
+		"_bytes":   ByteSizeFormatter(ByteUnitsBinary),
+		"_pct":     PercentFormatter(1),
+		"_percent": PercentFormatter(1),
+	}
+}
+
+// WebUnitFormatters returns unit formatters for common web fields: user agents
+// (user_agent), URLs (url, uri) and query strings (query).
+func WebUnitFormatters() map[string]Formatter {
+	return map[string]Formatter{
+		"user_agent": UserAgentFormatter(),
+		"url":        URLFormatter(),
+		"uri":        URLFormatter(),
+		"query":      URLDecodeFormatter(),
+	}
+}
+
+// unitFormatter returns the unit formatter of the longest suffix of key registered in
+// UnitFormatters.
+func (w KeyValueWriter) unitFormatter(key string) (Formatter, bool) {
+	var best string
+	var f Formatter
+	for suffix, uf := range w.UnitFormatters {
+		if uf != nil && len(suffix) > len(best) && strings.HasSuffix(key, suffix) {
+			best, f = suffix, uf
+		}
+	}
+	return f, f != nil
+}
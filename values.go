@@ -0,0 +1,28 @@
+package kvwriter
+
+import "bytes"
+
+// writeValues appends the values of Columns to buf, separated by the pairs delimiter.
+// Values are formatted and colored like pair values but never quoted, and missing keys
+// are skipped.
+func (w KeyValueWriter) writeValues(evt map[string]interface{}, buf *bytes.Buffer) {
+	_, fv := w.formatters()
+	var pd = w.pairsDelimiter()
+	var written int
+	for _, col := range w.Columns {
+		value, ok := evt[col]
+		if !ok || !w.keepKey(col) {
+			continue
+		}
+		if written > 0 {
+			buf.WriteString(pd)
+		}
+		written++
+
+		s, _ := w.formatValue(col, value, fv)
+		if w.colorEnabled() {
+			s = Colored(s, w.valueColor(col, value))
+		}
+		buf.WriteString(s)
+	}
+}
@@ -0,0 +1,153 @@
+package kvwriter
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// userAgentBrowsers lists the product tokens of browsers in the order they are detected,
+// since browsers include the tokens of others, e.g. Edge those of Chrome and Safari.
+var userAgentBrowsers = [...]struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"MSIE ", "Internet Explorer"},
+}
+
+// userAgentSystems lists the tokens of operating systems in the order they are detected,
+// e.g. Android before Linux and iOS before macOS.
+var userAgentSystems = [...]struct{ token, name string }{
+	{"Windows Phone", "Windows Phone"},
+	{"Windows", "Windows"},
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// UserAgentFormatter returns a Formatter summarizing user agents as the browser, its major
+// version and the operating system, e.g. "Chrome 120 on macOS". Other clients, such as
+// curl or crawlers, are summarized by their product token, e.g. "curl/8.4.0". Values that
+// are not recognized are written as is.
+func UserAgentFormatter() Formatter {
+	return func(i interface{}) string {
+		ua, ok := i.(string)
+		if !ok {
+			return defaultFormatValue(i)
+		}
+		return summarizeUserAgent(ua)
+	}
+}
+
+func summarizeUserAgent(ua string) string {
+	browser := userAgentBrowser(ua)
+	if browser == "" {
+		return ua
+	}
+	for _, s := range userAgentSystems {
+		if strings.Contains(ua, s.token) {
+			return browser + " on " + s.name
+		}
+	}
+	return browser
+}
+
+// userAgentBrowser returns the browser and its major version, or the product token of
+// other clients.
+func userAgentBrowser(ua string) string {
+	for _, b := range userAgentBrowsers {
+		if i := strings.Index(ua, b.token); i >= 0 {
+			return withMajorVersion(b.name, ua[i+len(b.token):])
+		}
+	}
+	if strings.Contains(ua, "Trident/") {
+		return "Internet Explorer 11"
+	}
+	if i := strings.Index(ua, "Version/"); i >= 0 && strings.Contains(ua, "Safari/") {
+		return withMajorVersion("Safari", ua[i+len("Version/"):])
+	}
+	if _, rest, ok := strings.Cut(ua, "compatible; "); ok {
+		if end := strings.IndexAny(rest, ";)"); end > 0 {
+			return rest[:end]
+		}
+	}
+	if token, _, _ := strings.Cut(ua, " "); token != "" && !strings.HasPrefix(token, "Mozilla/") {
+		return token
+	}
+	return ""
+}
+
+// withMajorVersion appends the leading digits of version to name, if any.
+func withMajorVersion(name, version string) string {
+	var n int
+	for n < len(version) && version[n] >= '0' && version[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return name
+	}
+	return name + " " + version[:n]
+}
+
+// URLFormatter returns a Formatter summarizing URLs as their host and decoded path,
+// followed by the number of query parameters, e.g. "example.com/a b (2 query params)".
+// Values that are not URLs are written as is.
+func URLFormatter() Formatter {
+	return func(i interface{}) string {
+		s, ok := i.(string)
+		if !ok {
+			return defaultFormatValue(i)
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Opaque != "" {
+			return s
+		}
+
+		var summary = u.Host + u.Path
+		if u.RawQuery != "" {
+			var n int
+			for _, param := range strings.Split(u.RawQuery, "&") {
+				if param != "" {
+					n++
+				}
+			}
+			if summary != "" {
+				summary += " "
+			}
+			if n == 1 {
+				summary += "(1 query param)"
+			} else {
+				summary += "(" + strconv.Itoa(n) + " query params)"
+			}
+		}
+		if summary == "" {
+			return s
+		}
+		return summary
+	}
+}
+
+// URLDecodeFormatter returns a Formatter decoding percent-encoding and plus signs, as in
+// query strings. Values that are not validly encoded are written as is.
+func URLDecodeFormatter() Formatter {
+	return func(i interface{}) string {
+		s, ok := i.(string)
+		if !ok {
+			return defaultFormatValue(i)
+		}
+		if decoded, err := url.QueryUnescape(s); err == nil {
+			return decoded
+		}
+		return s
+	}
+}
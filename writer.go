@@ -3,22 +3,14 @@ package kvwriter
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"sort"
-	"strconv"
-	"sync"
-
-	"github.com/jeremywohl/flatten"
-)
-
-var (
-	kvBufPool = sync.Pool{
-		New: func() interface{} {
-			return bytes.NewBuffer(make([]byte, 0, 100))
-		},
-	}
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Formatter transforms the input into a formatted string.
@@ -29,145 +21,1240 @@ type KeyValueWriter struct {
 	// Out is the output destination.
 	Out io.Writer
 
+	// FallbackOut receives the lines that could not be written to Out, e.g. os.Stderr.
+	FallbackOut io.Writer
+
+	// OnWriteError is called with the errors of writing to Out instead of returning them.
+	OnWriteError func(err error)
+
+	// Locking serializes the writes to Out of all copies of the writer, so lines written
+	// from multiple goroutines are never interleaved. Requires a writer created by
+	// NewKeyValueWriter. To share Out between writers, use a SyncWriter. (default: false)
+	Locking bool
+
+	// FlushSize and FlushInterval buffer the output until it reaches FlushSize bytes or
+	// FlushInterval passed since the first buffered line, reducing the writes to Out.
+	// Flush and Close write the buffered output. Requires a writer created by
+	// NewKeyValueWriter. (default: 0, disabled)
+	FlushSize     int
+	FlushInterval time.Duration
+
+	// MaxBufferSize is the largest capacity of the buffers events are formatted in that is
+	// reused by later writes. Buffers grown by larger input are left to the garbage
+	// collector, so a burst of huge events does not pin their memory. Zero reuses buffers
+	// of any size. (default: 64 KiB)
+	MaxBufferSize int
+
+	// InputFormat defines the format of the input passed to Write. (default: InputJSON)
+	InputFormat InputFormat
+
+	// ArrayInput defines how top-level JSON arrays are decoded. (default: ArrayInputSplit)
+	ArrayInput ArrayInputMode
+
+	// ScalarMode defines how top-level JSON scalars and null are decoded.
+	// (default: ScalarWrap)
+	ScalarMode ScalarMode
+
+	// ScalarKey is the key top-level scalars are written under by ScalarWrap.
+	// (default: "value")
+	ScalarKey string
+
+	// Decoder decodes the input passed to Write, replacing InputFormat, e.g. for binary
+	// formats. See the kvmsgpack package.
+	Decoder Decoder
+
+	// NewJSONDecoder creates the decoder of JSON input, e.g. to use a faster JSON library.
+	// (default: encoding/json with UseNumber)
+	NewJSONDecoder func(r io.Reader) JSONDecoder
+
+	// ErrorMode defines what happens to input that cannot be decoded. (default: ErrorFail)
+	ErrorMode ErrorMode
+
+	// PassThroughInvalid is a shorthand for ErrorMode ErrorPassThrough. (default: false)
+	PassThroughInvalid bool
+
+	// PassThroughPrefix is prepended to the input written by ErrorPassThrough.
+	PassThroughPrefix string
+
+	// OnDecodeError is called with input that cannot be decoded and the decode error,
+	// taking precedence over ErrorMode. The returned bytes are written to Out unchanged,
+	// a returned error is returned by Write as the Err of a DecodeError.
+	OnDecodeError func(p []byte, err error) ([]byte, error)
+
 	// PairsDelimiter defines a character to delimit individual pairs. (default: ' ')
 	PairsDelimiter rune
 
 	// KeyValueDelimiter defines a character to delimit key and value. (default: '=')
 	KeyValueDelimiter rune
 
+	// PairsSeparator defines a string to delimit individual pairs, e.g. ", ". If not empty,
+	// it is used instead of PairsDelimiter.
+	PairsSeparator string
+
+	// KeyValueSeparator defines a string to delimit key and value, e.g. " => ". If not
+	// empty, it is used instead of KeyValueDelimiter.
+	KeyValueSeparator string
+
 	// QuoteValues defines if you want to quote values. If enabled it will quote all values
 	// for consistency. If PairsDelimiter doesn't occur in the keys nor values
-	// then you don't need to quote values. (default: true)
+	// then you don't need to quote values. Keys are quoted only when they contain a
+	// delimiter, quotes, backslashes or non-printable characters. (default: true)
 	QuoteValues bool
 
+	// PreserveTypes writes numbers and booleans unquoted even if QuoteValues is enabled, so
+	// they can be told from strings. Values changed by a value formatter are quoted as
+	// usual. (default: false)
+	PreserveTypes bool
+
+	// QuoteMode defines which values are quoted. QuoteAuto quotes only values that cannot
+	// be read back unquoted, ignoring QuoteValues. (default: QuoteDefault)
+	QuoteMode QuoteMode
+
+	// QuoteKeys quotes all keys, e.g. for downstream parsers reading dots in keys as
+	// nesting. Ignored in LogfmtMode. (default: false)
+	QuoteKeys bool
+
+	// QuoteChar defines the character quoting keys and values: a double quote, a single
+	// quote or a backtick. Quotes and backslashes are escaped with a backslash,
+	// non-printable characters as in Go string literals. LogfmtMode always uses double
+	// quotes. (default: '"')
+	QuoteChar rune
+
+	// FastPath writes JSON objects while scanning them, without decoding them into maps,
+	// when the configuration does not need the whole event: no field ordering, alignment,
+	// multiline or nested output, key transformations, filters, transformers, level
+	// filtering, sampling, rate limiting, deduplication, redaction, line limits, OmitEmpty,
+	// NullOmit, CollisionMode, Template, FormatExtra or BeforeWrite hook. Pairs are
+	// written in input order instead of sorted. Input with duplicate flattened keys and
+	// other input the scanner does not support is written by the regular path.
+	// (default: false)
+	FastPath bool
+
+	// OutputFormat defines the format of the written events. (default: OutputKeyValue)
+	OutputFormat OutputFormat
+
+	// Columns defines the keys written by OutputCSV, OutputTSV, OutputTable and
+	// OutputValues, in order, e.g. "time", "level" and "message". Missing keys are written
+	// as empty fields, or skipped by OutputValues.
+	Columns []string
+
+	// ExtraColumn is the name of a last column holding the other pairs of the event as a
+	// JSON object. (default: "", the other pairs are not written)
+	ExtraColumn string
+
+	// ColumnsHeader writes the names of the columns as the first record. Requires a writer
+	// created by NewKeyValueWriter. (default: false)
+	ColumnsHeader bool
+
+	// TableRows is the number of events OutputTable buffers to size the columns to their
+	// widest values. Later events are written immediately, cut to these widths. Flush and
+	// Close write the buffered events. Requires a writer created by NewKeyValueWriter.
+	// (default: 20)
+	TableRows int
+
+	// ColumnWidths declares the widths of Columns for OutputTable, which then writes every
+	// event immediately instead of buffering TableRows events. (default: nil)
+	ColumnWidths []int
+
+	// Template defines the layout of the lines of OutputKeyValue as a text/template, e.g.
+	// '{{.time}} {{.level | upper | pad 5}} {{.message}} {{rest}}'. It is executed with
+	// the formatted values of the kept keys, without quotes and colors. Keys containing
+	// dots are read with '{{index . "http.method"}}'. The functions upper, lower and pad
+	// are available, and rest writes the pairs the template does not refer to.
+	// (default: "", pairs only)
+	Template string
+
+	// LogfmtMode emits strictly valid logfmt. Values are quoted only when they contain
+	// spaces, '=', quotes or control characters, in which case quotes, backslashes and
+	// control characters are escaped. Invalid characters in keys are replaced with '_'.
+	// QuoteValues is ignored and the delimiters must be ' ' and '='. (default: false)
+	LogfmtMode bool
+
 	// KeysExclude defines keys to not display in output. JSON structure is flattened so
 	// json '{"event": {"name": "x"}}' would produce 'event.name' key with 'x' as a value.
+	// Keys may be glob patterns where '*' matches any sequence of characters and '?' matches
+	// a single character, so 'http.headers.*' excludes the whole subtree.
 	KeysExclude []string
 
+	// KeysInclude defines keys to display in output. If not empty, only the listed keys are
+	// displayed and everything else is dropped. Nested keys are selected by their flattened
+	// names, e.g. 'event.name', and may be glob patterns like KeysExclude. KeysExclude is
+	// applied after KeysInclude.
+	KeysInclude []string
+
+	// KeysExcludeRegex defines regular expressions matching keys to not display in output,
+	// e.g. '^kubernetes\.'.
+	KeysExcludeRegex []*regexp.Regexp
+
+	// KeysIncludeRegex defines regular expressions matching keys to display in output.
+	// If not empty, the writer is in allowlist mode and a key is displayed when it matches
+	// KeysInclude or KeysIncludeRegex.
+	KeysIncludeRegex []*regexp.Regexp
+
+	// FieldsOrder defines keys that are always written first, in the listed order. The
+	// remaining keys follow sorted alphabetically, or by KeySort when set.
+	FieldsOrder []string
+
+	// KeySort reports whether key a should be written before key b. It orders the keys not
+	// listed in FieldsOrder. (default: alphabetical)
+	KeySort func(a, b string) bool
+
+	// AlignValues pads every pair but the last with spaces to its column width so that
+	// consecutive lines line up vertically. (default: false)
+	AlignValues bool
+
+	// AlignWidth defines a fixed column width for AlignValues. If 0, the width of every key
+	// is the widest pair seen so far, which requires a writer created by NewKeyValueWriter.
+	AlignWidth int
+
+	// Multiline writes every pair on its own line prefixed with MultilineIndent and ends
+	// every event with an EventSeparator line. (default: false)
+	Multiline bool
+
+	// MultilineIndent defines the prefix of every pair in Multiline mode. (default: "  ")
+	MultilineIndent string
+
+	// EventSeparator defines the line written after every event in Multiline mode, e.g.
+	// "---". (default: "", a blank line)
+	EventSeparator string
+
+	// JSONStrings defines how string values holding JSON objects or arrays, e.g. written by
+	// producers encoding JSON twice, are written. (default: JSONStringsKeep)
+	JSONStrings JSONStringMode
+
+	// ErrorKeys detects error objects in the values of the keys, or glob patterns, e.g.
+	// "error": objects with a message ("message", "msg", "error" or "err") and wrapped
+	// errors ("cause", "causes", "wrapped" or "errors"), which are objects, arrays or
+	// messages themselves. The chain is written as a single value formatted by FormatError
+	// instead of as deeply flattened keys. Nested errors are matched by their flattened
+	// key, e.g. "request.error" or "*.error". (default: nil)
+	ErrorKeys []string
+
+	// ExpandErrors writes the messages of detected error chains as separate keys, the
+	// outermost under the key of the error and the wrapped ones under the key joined with
+	// their index, e.g. "error.1". (default: false)
+	ExpandErrors bool
+
+	// FormatError formats the messages of detected error chains, ordered from the
+	// outermost to the innermost error. (default: nil, the outermost message followed by
+	// the number of wrapped errors, e.g. "open file: permission denied (3 wrapped)")
+	FormatError func(messages []string) string
+
+	// StackTraceKeys writes the string values of the keys, or glob patterns, e.g. "stack"
+	// or "error.stack", after the pairs on continuation lines instead of as quoted values.
+	// The key is written on its own line prefixed with StackTraceIndent, or MultilineIndent
+	// in Multiline mode, and every line of the trace is indented by another
+	// StackTraceIndent.
+	StackTraceKeys []string
+
+	// StackTraceIndent defines the indentation of stack traces. (default: "    ")
+	StackTraceIndent string
+
+	// StackTracePathSegments keeps only the last N segments of absolute file paths in
+	// stack traces, like CallerPathSegments. (default: 0, full paths)
+	StackTracePathSegments int
+
+	// FlattenStyle defines how the keys of nested objects are joined. (default: FlattenDot)
+	FlattenStyle FlattenStyle
+
+	// FlattenSeparator defines a custom string joining the keys of nested objects, e.g. "__".
+	// If not empty, it takes precedence over FlattenStyle.
+	FlattenSeparator string
+
+	// CollisionMode defines what happens when flattening results in a key that is already
+	// set, e.g. {"a.b":1,"a":{"b":2}}. (default: CollisionLastWins)
+	CollisionMode CollisionMode
+
+	// MaxDepth limits flattening to N levels. Objects and arrays nested deeper are written
+	// as a single compact JSON value. (default: 0, unlimited)
+	MaxDepth int
+
+	// ArrayMode defines how arrays are written. (default: ArrayIndexKeys)
+	ArrayMode ArrayMode
+
+	// ArrayJoin defines the string joining array elements in ArrayJoinValues mode.
+	// (default: ",")
+	ArrayJoin string
+
+	// Nested writes objects and arrays preserving their hierarchy, e.g.
+	// 'http={method="GET" status="200"}', instead of flattening them. Keys are still
+	// filtered, ordered and formatted by their flattened names. AlignValues and Multiline
+	// are ignored. (default: false)
+	Nested bool
+
+	// KeyCase converts the words of flattened keys to the given case, e.g. 'http.statusCode'
+	// to 'http.status_code' with KeyCaseSnake. Filters, ordering and formatters see the
+	// converted keys. Keys converted to a key that is already set, e.g. 'userId' and
+	// 'user_id' with KeyCaseSnake, are handled by CollisionMode. (default: KeyCaseNone)
+	KeyCase KeyCase
+
+	// KeysRename maps flattened keys to the names they are written with, e.g.
+	// 'kubernetes.pod_name' to 'pod'. Filters, ordering and formatters see the new names.
+	// In Nested mode only top-level keys are renamed. Keys renamed to a key that is already
+	// set are handled by CollisionMode.
+	KeysRename map[string]string
+
+	// KeyTrimPrefixes defines prefixes removed from flattened keys, e.g. 'fields.'. Only the
+	// first matching prefix is removed and a key is never trimmed to an empty string.
+	KeyTrimPrefixes []string
+
+	// MaxValueLength limits formatted values to N runes. Longer values are cut and Ellipsis
+	// is appended. (default: 0, unlimited)
+	MaxValueLength int
+
+	// Ellipsis is appended to truncated values. (default: "…")
+	Ellipsis string
+
+	// ShowTruncatedBytes appends the number of removed bytes to truncated values,
+	// e.g. 'abc…(+1234 bytes)'. (default: false)
+	ShowTruncatedBytes bool
+
+	// MaxLineLength limits the visible width of lines. Pairs that do not fit are handled
+	// according to LineOverflow. Multiline mode ignores it. (default: 0, unlimited)
+	MaxLineLength int
+
+	// LineOverflow defines what happens with pairs exceeding MaxLineLength.
+	// (default: OverflowWrap)
+	LineOverflow LineOverflow
+
+	// WrapIndent prefixes continuation lines in OverflowWrap mode. (default: "  ↳ ")
+	WrapIndent string
+
+	// FilterEvent is called with the flattened event before formatting. If it returns false
+	// the event is dropped and nothing is written. In Nested mode the event is not flattened.
+	//
+	// Deprecated: Add a FilterTransformer to Pipeline instead, as WithFilterEvent does.
+	FilterEvent func(map[string]interface{}) bool
+
+	// Pipeline transforms the flattened events after FilterEvent, e.g. to filter, redact,
+	// rename or enrich them with reusable transformers. In Nested mode the events are not
+	// flattened.
+	Pipeline Pipeline
+
+	// TimestampFieldName defines the key holding the event timestamp. (default: "time")
+	TimestampFieldName string
+
+	// TimeLayout defines the layout used to render the timestamp, e.g. "15:04:05.000".
+	// RFC3339 strings and Unix epoch numbers are parsed and re-rendered in local time.
+	// If empty, the timestamp is written as is.
+	TimeLayout string
+
+	// FormatTimestamp formats the timestamp field. It replaces the TimeLayout rendering.
+	FormatTimestamp Formatter
+
+	// LevelFieldName defines the key holding the event level. (default: "level")
+	LevelFieldName string
+
+	// MinLevel drops events whose level is below it. Events without a known level are
+	// kept. (default: LevelUnset)
+	MinLevel Level
+
+	// Sampler decides whether events below LevelError are written, e.g. to tail chatty
+	// services. See NewEveryNthSampler, NewRandomSampler and NewBurstSampler.
+	Sampler Sampler
+
+	// RateLimit suppresses events beyond RateLimit per RateLimitPeriod with the same value
+	// of the RateLimitKey field, e.g. "message". When the period of a value with suppressed
+	// events ends, or on Close, a summary event with the value and the number of
+	// suppressed events under SuppressedFieldName is written. Summaries are not sampled.
+	// They are written by a timer, so writers used concurrently need Locking. Requires a
+	// writer created by NewKeyValueWriter. (default: 0, disabled)
+	RateLimit       int
+	RateLimitKey    string
+	RateLimitPeriod time.Duration
+
+	// Dedup collapses consecutive identical lines into one. When a different line follows,
+	// "(repeated N times)" is written before it. Lines are compared as formatted, so
+	// changing fields like timestamps have to be excluded. (default: false)
+	Dedup bool
+
+	// LevelTable maps lower-cased level names to levels. (default: DefaultLevels)
+	LevelTable map[string]Level
+
+	// AbbreviateLevels shortens level values to three letters, e.g. "information" to "INF".
+	AbbreviateLevels bool
+
+	// LevelWidth pads level values with spaces to the given width. (default: 0, no padding)
+	LevelWidth int
+
+	// LevelIcons prefixes or replaces level values with the icons of LevelIconSymbols. Icons
+	// are colored like the level. (default: LevelIconsOff)
+	LevelIcons LevelIconMode
+
+	// LevelIconSymbols maps level names to icons. Names are matched case-insensitively and
+	// by their abbreviation. (default: DefaultLevelIcons)
+	LevelIconSymbols map[string]string
+
+	// FormatLevel formats the level field. It replaces AbbreviateLevels, LevelIcons and
+	// LevelWidth.
+	FormatLevel Formatter
+
+	// MessageFieldName defines the key holding the event message. (default: "message")
+	MessageFieldName string
+
+	// UnquotedMessage writes the message value without quotes even if QuoteValues is enabled.
+	// Use FieldsOrder to place the message at a fixed position.
+	UnquotedMessage bool
+
+	// FormatMessage formats the message field.
+	FormatMessage Formatter
+
+	// CallerFieldName defines the key holding the event caller. (default: "caller")
+	CallerFieldName string
+
+	// CallerPathSegments keeps only the last N path segments of the caller, e.g. with 2
+	// '/src/app/internal/http/server.go:123' is written as 'http/server.go:123'.
+	// (default: 0, the full path)
+	CallerPathSegments int
+
+	// FormatCaller formats the caller field. It replaces CallerPathSegments.
+	FormatCaller Formatter
+
+	// Colorize defines when the output is colorized with ANSI escape sequences. In ColorAuto
+	// mode, NewKeyValueWriter detects the terminal once; writers created otherwise detect it
+	// on every Write. (default: ColorNever)
+	Colorize ColorMode
+
+	// KeyColor defines the color of keys. (default: ColorDim)
+	KeyColor Color
+
+	// ValueColor defines the color of values. (default: none)
+	ValueColor Color
+
+	// LevelColors maps level names to the colors of level values. Names are matched
+	// case-insensitively and by their abbreviation. (default: DefaultLevelColors)
+	LevelColors map[string]Color
+
+	// Theme defines the colors of keys, values by their type and levels. If set, it
+	// replaces KeyColor, ValueColor and LevelColors.
+	Theme *Theme
+
+	// RedactValues defines rules masking parts of values, e.g. RedactCreditCards. They are
+	// applied after FilterEvent and before any formatter, to string and number values.
+	RedactValues []RedactionRule
+
+	// RedactKeys defines fields whose values are replaced with RedactMask, e.g. 'password'
+	// or '*_secret'. Keys may be glob patterns like KeysExclude and are matched, ignoring
+	// case, against both the flattened key and the name of the field itself before keys
+	// are renamed. Redacted objects and arrays are replaced as a whole.
+	RedactKeys []string
+
+	// RedactMask replaces the values of RedactKeys. (default: "***")
+	RedactMask string
+
+	// HashKeys defines fields whose values are replaced with a short salted hash, so events
+	// can be correlated by e.g. user ID or email without exposing them. Keys are matched
+	// like RedactKeys, which take precedence.
+	HashKeys []string
+
+	// HashSalt keys the HMAC-SHA256 hash of HashKeys values.
+	HashSalt string
+
+	// HashLength defines the number of hex digits of the hash written. (default: 12)
+	HashLength int
+
+	// DurationKeys maps keys, or glob patterns like "*_ms", to the unit of their numeric
+	// values, rendering them as human readable durations, e.g. 1532 ms as 1.53s.
+	DurationKeys map[string]time.Duration
+
+	// DurationPrecision is the maximum number of decimal places of humanized durations.
+	// (default: 2)
+	DurationPrecision int
+
+	// ByteSizeKeys renders the numeric values of the keys, or glob patterns, as human
+	// readable byte sizes, e.g. 1048576 as 1.0MiB.
+	ByteSizeKeys []string
+
+	// ByteUnits defines the units of humanized byte sizes. (default: ByteUnitsBinary)
+	ByteUnits ByteUnits
+
+	// EpochKeys renders the numeric values of the keys, or glob patterns, as timestamps
+	// in TimeLayout, or RFC3339 in UTC if not set. The unit of the epoch is detected by its
+	// magnitude.
+	EpochKeys []string
+
+	// DetectEpochs renders integers of any key that are epoch timestamps between the years
+	// 2000 and 2100 like EpochKeys. (default: false)
+	DetectEpochs bool
+
+	// UnitFormatters maps key suffixes, e.g. "_ms", to formatters transforming the values
+	// of matching keys before FormatValue. The longest matching suffix wins. See
+	// DefaultUnitFormatters.
+	UnitFormatters map[string]Formatter
+
+	// BoolFormat defines how booleans are rendered. (default: BoolTrueFalse)
+	BoolFormat BoolFormat
+
+	// NullMode defines how null values are rendered. (default: NullLiteral)
+	NullMode NullMode
+
+	// NullText is written for null values by NullPlaceholder. (default: "<nil>")
+	NullText string
+
+	// OmitEmpty drops keys whose values are "", 0, false, null or empty arrays or objects.
+	// (default: false)
+	OmitEmpty bool
+
+	// NumberFormat defines how numbers are rendered by the default value formatter.
+	NumberFormat NumberFormat
+
+	// FormatKey and FormatValue transform keys and values before they are written.
 	FormatKey   Formatter
 	FormatValue Formatter
 
+	// FormatFieldValue defines value formatters for individual flattened keys, e.g.
+	// 'duration_ms' or 'http.bytes'. They take precedence over all other value formatters.
+	FormatFieldValue map[string]Formatter
+
+	// FormatExtra can append extra output after the pairs.
 	FormatExtra func(map[string]interface{}, *bytes.Buffer) error
+
+	// Hooks are called before formatting and after writing events.
+	Hooks Hooks
+
+	// state is shared by the copies of a writer created by NewKeyValueWriter.
+	state *writerState
+
+	// filter holds the include and exclude filters and the fields order compiled by
+	// NewKeyValueWriter.
+	filter *keyFilter
+
+	// template holds Template compiled by NewKeyValueWriter.
+	template *lineTemplate
+
+	// autoColor caches the ColorAuto detection done by NewKeyValueWriter:
+	// 0 not detected, 1 colorized, -1 plain.
+	autoColor int8
+
+	// optionErr is an invalid value found by an option, e.g. FromEnv, reported by Validate.
+	optionErr error
 }
 
 // NewKeyValueWriter creates and initializes a new KeyValueWriter.
-func NewKeyValueWriter(options ...func(w *KeyValueWriter)) KeyValueWriter {
+// It panics if the options result in an invalid configuration, use New to handle it.
+func NewKeyValueWriter(options ...Option) KeyValueWriter {
+	w, err := New(options...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return w
+}
+
+// New creates and initializes a new KeyValueWriter like NewKeyValueWriter, reporting an
+// invalid configuration as an error.
+func New(options ...Option) (KeyValueWriter, error) {
 	w := KeyValueWriter{
 		Out:               os.Stdout,
 		PairsDelimiter:    ' ',
 		KeyValueDelimiter: '=',
 		QuoteValues:       true,
+		QuoteChar:         '"',
+		ScalarKey:         "value",
+
+		TimestampFieldName: "time",
+		LevelFieldName:     "level",
+		MessageFieldName:   "message",
+		CallerFieldName:    "caller",
+
+		KeyColor: ColorDim,
+
+		MultilineIndent:  "  ",
+		StackTraceIndent: "    ",
+		ArrayJoin:        ",",
+		Ellipsis:         "…",
+		WrapIndent:       "  ↳ ",
+		RedactMask:       "***",
+		HashLength:       12,
+		NullText:         "<nil>",
+
+		DurationPrecision: 2,
+
+		MaxBufferSize: defaultMaxBufferSize,
+		TableRows:     20,
+
+		state: newWriterState(),
 	}
 
 	for _, opt := range options {
 		opt(&w)
 	}
 
-	return w
+	w.detectAutoColor()
+	w.compileFilters()
+	w.compileTemplate()
+
+	if err := w.Validate(); err != nil {
+		return KeyValueWriter{}, fmt.Errorf("kvwriter: %w", err)
+	}
+	return w, nil
+}
+
+// Validate reports whether the writer configuration is usable.
+func (w KeyValueWriter) Validate() error {
+	if w.optionErr != nil {
+		return w.optionErr
+	}
+	if w.Out == nil {
+		return errors.New("output is nil")
+	}
+	var pd, kvd = w.pairsDelimiter(), w.keyValueDelimiter()
+	if err := validateDelimiter("pairs", pd); err != nil {
+		return err
+	}
+	if err := validateDelimiter("key-value", kvd); err != nil {
+		return err
+	}
+	if pd == kvd {
+		return fmt.Errorf("pairs and key-value delimiters are both %q", pd)
+	}
+	if w.LogfmtMode && (pd != " " || kvd != "=") {
+		return fmt.Errorf("logfmt mode requires \" \" and \"=\" delimiters, got %q and %q", pd, kvd)
+	}
+	if w.QuoteMode < QuoteDefault || w.QuoteMode > QuoteAuto {
+		return fmt.Errorf("unknown quote mode %d", w.QuoteMode)
+	}
+	switch w.QuoteChar {
+	case 0, '"', '\'', '`':
+	default:
+		return fmt.Errorf("invalid quote character %q", w.QuoteChar)
+	}
+	if w.FlattenStyle < FlattenDot || w.FlattenStyle > FlattenRails {
+		return fmt.Errorf("unknown flatten style %d", w.FlattenStyle)
+	}
+	if w.CollisionMode < CollisionLastWins || w.CollisionMode > CollisionError {
+		return fmt.Errorf("unknown collision mode %d", w.CollisionMode)
+	}
+	if w.Locking && w.state == nil {
+		return errors.New("locking requires a writer created by NewKeyValueWriter")
+	}
+	if (w.FlushSize > 0 || w.FlushInterval > 0) && w.state == nil {
+		return errors.New("batching requires a writer created by NewKeyValueWriter")
+	}
+	if w.ColumnsHeader && w.state == nil {
+		return errors.New("columns header requires a writer created by NewKeyValueWriter")
+	}
+	if (w.OutputFormat == OutputCSV || w.OutputFormat == OutputTSV) && len(w.Columns) == 0 && w.ExtraColumn == "" {
+		return errors.New("CSV and TSV output require columns")
+	}
+	if w.OutputFormat == OutputValues && len(w.Columns) == 0 {
+		return errors.New("values output requires columns")
+	}
+	if w.OutputFormat == OutputTable {
+		if err := w.validateTable(); err != nil {
+			return err
+		}
+	}
+	if w.Template != "" {
+		if _, err := w.lineTemplate(); err != nil {
+			return fmt.Errorf("invalid template: %s", err)
+		}
+	}
+	if w.FlushSize < 0 {
+		return fmt.Errorf("negative flush size %d", w.FlushSize)
+	}
+	if w.MaxBufferSize < 0 {
+		return fmt.Errorf("negative max buffer size %d", w.MaxBufferSize)
+	}
+	if w.InputFormat < InputJSON || w.InputFormat > InputGELF {
+		return fmt.Errorf("unknown input format %d", w.InputFormat)
+	}
+	if w.OutputFormat < OutputKeyValue || w.OutputFormat > OutputValues {
+		return fmt.Errorf("unknown output format %d", w.OutputFormat)
+	}
+	if w.ArrayInput < ArrayInputSplit || w.ArrayInput > ArrayInputIndexed {
+		return fmt.Errorf("unknown array input mode %d", w.ArrayInput)
+	}
+	if w.ByteUnits < ByteUnitsBinary || w.ByteUnits > ByteUnitsDecimal {
+		return fmt.Errorf("unknown byte units %d", w.ByteUnits)
+	}
+	if w.MinLevel < LevelUnset || w.MinLevel > LevelEmergency {
+		return fmt.Errorf("unknown min level %d", w.MinLevel)
+	}
+	if w.NullMode < NullLiteral || w.NullMode > NullPlaceholder {
+		return fmt.Errorf("unknown null mode %d", w.NullMode)
+	}
+	if w.BoolFormat < BoolTrueFalse || w.BoolFormat > BoolOneZero {
+		return fmt.Errorf("unknown bool format %d", w.BoolFormat)
+	}
+	if w.ScalarMode < ScalarWrap || w.ScalarMode > ScalarFail {
+		return fmt.Errorf("unknown scalar mode %d", w.ScalarMode)
+	}
+	if w.ErrorMode < ErrorFail || w.ErrorMode > ErrorPassThrough {
+		return fmt.Errorf("unknown error mode %d", w.ErrorMode)
+	}
+	if w.ArrayMode < ArrayIndexKeys || w.ArrayMode > ArrayRawJSON {
+		return fmt.Errorf("unknown array mode %d", w.ArrayMode)
+	}
+	if w.KeyCase < KeyCaseNone || w.KeyCase > KeyCaseUpper {
+		return fmt.Errorf("unknown key case %d", w.KeyCase)
+	}
+	if w.MaxDepth < 0 {
+		return fmt.Errorf("negative max depth %d", w.MaxDepth)
+	}
+	if w.HashLength < 0 {
+		return fmt.Errorf("negative hash length %d", w.HashLength)
+	}
+	if w.MaxValueLength < 0 {
+		return fmt.Errorf("negative max value length %d", w.MaxValueLength)
+	}
+	if w.MaxLineLength < 0 {
+		return fmt.Errorf("negative max line length %d", w.MaxLineLength)
+	}
+	if w.LineOverflow < OverflowWrap || w.LineOverflow > OverflowElide {
+		return fmt.Errorf("unknown line overflow %d", w.LineOverflow)
+	}
+	if w.LevelWidth < 0 {
+		return fmt.Errorf("negative level width %d", w.LevelWidth)
+	}
+	if w.LevelIcons < LevelIconsOff || w.LevelIcons > LevelIconsReplace {
+		return fmt.Errorf("unknown level icon mode %d", w.LevelIcons)
+	}
+	if w.AlignWidth < 0 {
+		return fmt.Errorf("negative align width %d", w.AlignWidth)
+	}
+	if w.CallerPathSegments < 0 {
+		return fmt.Errorf("negative caller path segments %d", w.CallerPathSegments)
+	}
+	if w.JSONStrings < JSONStringsKeep || w.JSONStrings > JSONStringsIndent {
+		return fmt.Errorf("unknown JSON string mode %d", w.JSONStrings)
+	}
+	if w.StackTracePathSegments < 0 {
+		return fmt.Errorf("negative stack trace path segments %d", w.StackTracePathSegments)
+	}
+	if w.RateLimit < 0 {
+		return fmt.Errorf("negative rate limit %d", w.RateLimit)
+	}
+	if w.DurationPrecision < 0 {
+		return fmt.Errorf("negative duration precision %d", w.DurationPrecision)
+	}
+	if w.NumberFormat.Decimals < 0 {
+		return fmt.Errorf("negative number decimals %d", w.NumberFormat.Decimals)
+	}
+	for i, rule := range w.RedactValues {
+		if rule.Pattern == nil {
+			return fmt.Errorf("RedactValues[%d] has nil pattern", i)
+		}
+	}
+	if err := validateRegexps("KeysExcludeRegex", w.KeysExcludeRegex); err != nil {
+		return err
+	}
+	if err := validateRegexps("KeysIncludeRegex", w.KeysIncludeRegex); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateRegexps(name string, res []*regexp.Regexp) error {
+	for i, re := range res {
+		if re == nil {
+			return fmt.Errorf("%s[%d] is nil", name, i)
+		}
+	}
+	return nil
+}
+
+func validateDelimiter(name string, d string) error {
+	if d == "" || strings.ContainsRune(d, 0) || strings.ContainsRune(d, utf8.RuneError) || !utf8.ValidString(d) {
+		return fmt.Errorf("invalid %s delimiter %q", name, d)
+	}
+	return nil
 }
 
-// Write transforms the JSON input with formatters and appends to w.Out.
+// pairsDelimiter returns the string delimiting individual pairs.
+func (w KeyValueWriter) pairsDelimiter() string {
+	if w.PairsSeparator != "" {
+		return w.PairsSeparator
+	}
+	return runeString(w.PairsDelimiter)
+}
+
+// keyValueDelimiter returns the string delimiting key and value.
+func (w KeyValueWriter) keyValueDelimiter() string {
+	if w.KeyValueSeparator != "" {
+		return w.KeyValueSeparator
+	}
+	return runeString(w.KeyValueDelimiter)
+}
+
+// runeString converts r to a string, mapping the zero rune to an empty string.
+func runeString(r rune) string {
+	if r == 0 {
+		return ""
+	}
+	return string(r)
+}
+
+// Write transforms the JSON input with formatters and appends to w.Out. The input may
+// contain several newline-delimited JSON objects, each written as its own line. If the
+// input cannot be decoded as a whole, its lines are decoded one by one, so only the
+// invalid lines are handled according to ErrorMode and the valid events around them are
+// written. With ErrorFail, the events before the first invalid line are written and its
+// DecodeError returned. Events that cannot be rendered, e.g. with CollisionError, are
+// skipped and their errors returned, joined, after the other events are written. The
+// decoded events are reused by later writes, so hooks, filters,
+// samplers and transformers must not retain them.
 func (w KeyValueWriter) Write(p []byte) (n int, err error) {
-	var buf = kvBufPool.Get().(*bytes.Buffer)
-	defer func() {
-		buf.Reset()
-		kvBufPool.Put(buf)
-	}()
-
-	var evt map[string]interface{}
-	d := json.NewDecoder(bytes.NewReader(p))
-	d.UseNumber()
-	err = d.Decode(&evt)
+	var buf = getBuffer(len(p))
+	defer putBuffer(buf, w.MaxBufferSize)
+
+	if w.fastPathEnabled() {
+		if events, err := w.writeFast(p, buf); err == nil {
+			for i := 0; i < events; i++ {
+				w.countEvent(true)
+			}
+			return len(p), w.writeOut(buf)
+		}
+	}
+
+	events, err := w.decode(p)
 	if err != nil {
-		return n, fmt.Errorf("cannot decode event: %s", err)
+		if w.Decoder == nil && bytes.IndexByte(bytes.TrimSpace(p), '\n') >= 0 {
+			return w.writeLines(p, buf)
+		}
+		return w.decodeError(p, err, buf)
+	}
+	defer w.releaseEvents(events)
+
+	errs := w.renderEvents(events, buf, nil)
+	if buf.Len() > 0 {
+		if err := w.writeOut(buf); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return len(p), errors.Join(errs...)
+}
 
-	evt, err = flatten.Flatten(evt, "", flatten.DotStyle)
+// renderEvents renders the events to buf, skipping the events that fail to render and
+// appending their errors to errs.
+func (w KeyValueWriter) renderEvents(events []map[string]interface{}, buf *bytes.Buffer, errs []error) []error {
+	for _, evt := range events {
+		ok, err := w.renderEvent(evt, buf)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		w.countEvent(ok)
+	}
+	return errs
+}
+
+// writeLines writes the lines of p one by one, after decoding p as a whole failed.
+func (w KeyValueWriter) writeLines(p []byte, buf *bytes.Buffer) (int, error) {
+	var n int
+	var errs []error
+	for rest := p; len(rest) > 0; {
+		line, next, _ := bytes.Cut(rest, []byte{'\n'})
+		consumed := len(rest) - len(next)
+		rest = next
+		if len(bytes.TrimSpace(line)) == 0 {
+			n += consumed
+			continue
+		}
+
+		events, err := w.decode(line)
+		if err != nil {
+			if err := w.appendDecodeError(line, err, buf); err != nil {
+				errs = append(errs, err)
+				break
+			}
+			n += consumed
+			continue
+		}
+		errs = w.renderEvents(events, buf, errs)
+		w.releaseEvents(events)
+		n += consumed
+	}
+	if buf.Len() > 0 {
+		if err := w.writeOut(buf); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// decodeError handles input that cannot be decoded according to OnDecodeError and
+// ErrorMode.
+func (w KeyValueWriter) decodeError(p []byte, err error, buf *bytes.Buffer) (int, error) {
+	if err := w.appendDecodeError(p, err, buf); err != nil {
+		return 0, err
+	}
+	if err := w.writeOut(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// appendDecodeError appends the output for input that cannot be decoded to buf according
+// to OnDecodeError and ErrorMode, or returns the DecodeError to be returned by Write.
+func (w KeyValueWriter) appendDecodeError(p []byte, err error, buf *bytes.Buffer) error {
+	if errors.Is(err, errScalar) && w.ScalarMode == ScalarPassThrough {
+		writeRaw(buf, p)
+		return nil
+	}
+
+	w.countDecodeError()
+	switch {
+	case w.OnDecodeError != nil:
+		out, err := w.OnDecodeError(p, err)
+		if err != nil {
+			return &DecodeError{Input: bytes.Clone(p), Err: err}
+		}
+		buf.Write(out)
+	case w.PassThroughInvalid || w.ErrorMode == ErrorPassThrough:
+		buf.WriteString(w.PassThroughPrefix)
+		writeRaw(buf, p)
+	case w.ErrorMode == ErrorDrop:
+	default:
+		return &DecodeError{Input: bytes.Clone(p), Err: err}
+	}
+	return nil
+}
+
+// writeOut writes buf to Out, holding the lock with Locking, counts the written bytes and
+// calls the AfterWrite hook. With FlushSize or FlushInterval, buf is batched instead.
+func (w KeyValueWriter) writeOut(buf *bytes.Buffer) error {
+	if w.batching() {
+		return w.writeBatch(buf)
+	}
+
+	var line = buf.Bytes()
+	if w.Locking && w.state != nil {
+		w.state.outMu.Lock()
+	}
+	n, err := buf.WriteTo(w.Out)
+	if w.Locking && w.state != nil {
+		w.state.outMu.Unlock()
+	}
+
+	if w.state != nil {
+		w.state.stats.bytesWritten.Add(uint64(n))
+	}
+	if w.Hooks.AfterWrite != nil {
+		w.Hooks.AfterWrite(line, err)
+	}
 	if err != nil {
-		return n, fmt.Errorf("cannot flatten event: %s", err)
+		return w.writeError(line, &SinkError{Output: bytes.Clone(line), Err: err})
+	}
+	return nil
+}
+
+// writeError handles the error of writing line to Out: the line is written to FallbackOut
+// and the error reported to OnWriteError. The error is returned unless handled by either.
+func (w KeyValueWriter) writeError(line []byte, err error) error {
+	if w.FallbackOut != nil {
+		if _, ferr := w.FallbackOut.Write(line); ferr != nil {
+			err = fmt.Errorf("%w (fallback: %s)", err, ferr)
+		} else if w.OnWriteError == nil {
+			return nil
+		}
+	}
+	if w.OnWriteError != nil {
+		w.OnWriteError(err)
+		return nil
+	}
+	return err
+}
+
+// writeRaw appends p to buf, terminated by a newline.
+func writeRaw(buf *bytes.Buffer, p []byte) {
+	buf.Write(p)
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+}
+
+// renderEvent runs the decoded event through the pipeline and appends the formatted line
+// to buf. It reports false if the event was dropped.
+func (w KeyValueWriter) renderEvent(evt map[string]interface{}, buf *bytes.Buffer) (bool, error) {
+	w.checkFilters()
+	if w.Hooks.BeforeWrite != nil {
+		w.Hooks.BeforeWrite(evt)
+	}
+
+	if w.MinLevel > LevelUnset || w.Sampler != nil {
+		l := w.EventLevel(evt)
+		if l != LevelUnset && l < w.MinLevel {
+			return false, nil
+		}
+		if w.Sampler != nil && l < LevelError && !w.Sampler.Sample(evt, l) {
+			return false, nil
+		}
+	}
+
+	if w.JSONStrings == JSONStringsExpand {
+		expandJSONStrings(evt)
+	}
+
+	if len(w.RedactKeys) > 0 || len(w.HashKeys) > 0 {
+		w.maskKeys(evt, "")
+	}
+
+	if w.OmitEmpty {
+		omitValues(evt, isEmpty)
 	}
 
-	w.writePairs(evt, buf)
+	if len(w.ErrorKeys) > 0 {
+		w.collapseErrorChains(evt, "")
+	}
+
+	if w.MaxDepth > 0 {
+		limitDepth(evt, w.MaxDepth)
+	}
+
+	if w.ArrayMode != ArrayIndexKeys {
+		collapseArrays(evt, w.ArrayMode, w.ArrayJoin)
+	}
+
+	if !w.Nested {
+		if err := w.flattenEvent(evt); err != nil {
+			return false, err
+		}
+	}
+
+	if err := w.transformKeys(evt); err != nil {
+		return false, err
+	}
+
+	if w.FilterEvent != nil && !w.FilterEvent(evt) {
+		return false, nil
+	}
+
+	if len(w.Pipeline) > 0 {
+		var ok bool
+		if evt, ok = w.Pipeline.Transform(evt); !ok || evt == nil {
+			return false, nil
+		}
+	}
+
+	if w.RateLimit > 0 && w.RateLimitKey != "" {
+		ok, summary := w.rateLimit(evt)
+		if summary != nil {
+			if err := w.renderSummaries([]map[string]interface{}{summary}, buf); err != nil {
+				return false, err
+			}
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	if w.NullMode == NullOmit {
+		omitValues(evt, isNull)
+	}
+
+	if len(w.RedactValues) > 0 {
+		w.redactValues(evt)
+	}
+
+	var start = buf.Len()
+	switch {
+	case w.OutputFormat == OutputJournal:
+		w.writeJournal(evt, buf)
+	case w.OutputFormat == OutputJSON:
+		w.writeJSON(evt, buf)
+	case w.OutputFormat == OutputCSV || w.OutputFormat == OutputTSV:
+		w.writeCSV(evt, buf)
+	case w.OutputFormat == OutputValues:
+		w.writeValues(evt, buf)
+	case w.OutputFormat == OutputTable:
+		if !w.writeTable(evt, buf) {
+			// Buffered until the column widths are known.
+			return true, nil
+		}
+	case w.Template != "":
+		if err := w.writeTemplate(evt, buf); err != nil {
+			return false, err
+		}
+	case w.Nested:
+		w.writeNested(evt, buf)
+	default:
+		w.writePairs(evt, buf)
+	}
 
 	if w.FormatExtra != nil {
-		err = w.FormatExtra(evt, buf)
+		err := w.FormatExtra(evt, buf)
 		if err != nil {
-			return n, err
+			return false, fmt.Errorf("%w: %w", ErrFormat, err)
 		}
 	}
 
-	err = buf.WriteByte('\n')
-	if err != nil {
-		return n, err
+	if err := buf.WriteByte('\n'); err != nil {
+		return false, err
 	}
 
-	_, err = buf.WriteTo(w.Out)
-	return len(p), err
+	if w.Multiline && w.OutputFormat == OutputKeyValue {
+		buf.WriteString(w.EventSeparator)
+		buf.WriteByte('\n')
+	}
+
+	if w.Dedup && w.state != nil {
+		return w.state.dedup(buf, start), nil
+	}
+
+	return true, nil
 }
 
 // writePairs appends formatted key-value pairs to buf.
 func (w KeyValueWriter) writePairs(evt map[string]interface{}, buf *bytes.Buffer) {
-	var keys = make([]string, 0, len(evt))
-	for key := range evt {
-		var isExcluded bool
-		for _, excluded := range w.KeysExclude {
-			if key == excluded {
-				isExcluded = true
-				break
+	var pooled = w.sortedKeys(evt)
+	defer putKeys(pooled)
+	keys, continued := w.splitContinuations(evt, *pooled)
+
+	fk, fv := w.formatters()
+	pd := w.pairsDelimiter()
+	align := w.AlignValues
+	continuationIndent := w.StackTraceIndent
+
+	if w.Multiline {
+		pd = "\n" + w.MultilineIndent
+		align = false
+		continuationIndent = w.MultilineIndent
+		buf.WriteString(w.MultilineIndent)
+	}
+
+	limit := w.newLineLimiter(pd)
+
+	var start int
+	for i, key := range keys {
+		delimStart := buf.Len()
+		if i > 0 { // Skip PairsDelimiter for first field
+			if align {
+				w.alignPair(buf, keys[i-1], start)
 			}
+			buf.WriteString(pd)
 		}
-		if isExcluded {
-			continue
+
+		start = buf.Len()
+		w.writePair(buf, key, evt[key], fk, fv)
+
+		if limit != nil {
+			var ok bool
+			if start, ok = limit.fit(buf, delimStart, start, len(keys)-i); !ok {
+				break
+			}
 		}
-		keys = append(keys, key)
 	}
-	sort.Strings(keys)
 
-	fk := defaultFormatKey
-	fv := defaultFormatValue
+	for _, key := range continued {
+		w.writeContinuation(buf, key, evt[key].(string), fk, continuationIndent)
+	}
+}
 
+// formatters returns the key and value formatters. Nil formatters denote the default
+// formatting, writing strings and numbers as they are without calling a formatter.
+func (w KeyValueWriter) formatters() (fk, fv Formatter) {
+	if w.NumberFormat != (NumberFormat{}) {
+		fv = w.NumberFormat.formatter()
+	}
 	if w.FormatKey != nil {
 		fk = w.FormatKey
 	}
 	if w.FormatValue != nil {
 		fv = w.FormatValue
 	}
+	return fk, fv
+}
 
-	for i, key := range keys {
-		buf.WriteString(fk(key))
-		buf.WriteRune(w.KeyValueDelimiter)
-
-		switch value := evt[key].(type) {
-		case string:
-			buf.WriteString(quoteValue(fv(value), w.QuoteValues))
-		case json.Number:
-			buf.WriteString(quoteValue(fv(value), w.QuoteValues))
-		default:
-			b, err := json.Marshal(value)
-			if err != nil {
-				buf.WriteString(quoteValue(fmt.Sprintf("[error: %v]", err), w.QuoteValues))
-			} else {
-				buf.WriteString(quoteValue(fv(b), w.QuoteValues))
-			}
-		}
+// writePair appends a single formatted key-value pair to buf.
+func (w KeyValueWriter) writePair(buf *bytes.Buffer, key string, value interface{}, fk, fv Formatter) {
+	w.writeKey(buf, formatKey(fk, key))
+	w.writeValue(buf, key, value, fv)
+}
 
-		if i < len(keys)-1 { // Skip PairsDelimiter for last field
-			buf.WriteRune(w.PairsDelimiter)
-		}
+// formatKey formats key with fk, or returns it unchanged if fk is nil.
+func formatKey(fk Formatter, key string) string {
+	if fk == nil {
+		return key
 	}
+	return fk(key)
 }
 
-func quoteValue(v string, q bool) string {
-	if q {
-		return strconv.Quote(v)
+// writeKey appends the formatted key k followed by the key-value delimiter to buf.
+func (w KeyValueWriter) writeKey(buf *bytes.Buffer, k string) {
+	k = w.quoteKey(k)
+	if w.colorEnabled() {
+		buf.WriteString(Colored(k+w.keyValueDelimiter(), w.keyColor()))
+	} else {
+		buf.WriteString(k)
+		buf.WriteString(w.keyValueDelimiter())
 	}
-	return v
 }
 
-func defaultFormatKey(i interface{}) string {
-	return fmt.Sprintf("%s", i)
+// writeValue appends the formatted value of key to buf.
+func (w KeyValueWriter) writeValue(buf *bytes.Buffer, key string, value interface{}, fv Formatter) {
+	s, quoted := w.formatValue(key, value, fv)
+	if quoted {
+		s = w.quote(key, s)
+	}
+
+	if w.colorEnabled() {
+		s = Colored(s, w.valueColor(key, value))
+	}
+	buf.WriteString(s)
+}
+
+// formatValue formats the value of key without quotes and colors. It reports whether the
+// value may be quoted.
+func (w KeyValueWriter) formatValue(key string, value interface{}, fv Formatter) (s string, quoted bool) {
+	fv = w.fieldFormatter(key, fv)
+
+	quoted = true
+	switch v := value.(type) {
+	case nil:
+		// Nulls are never quoted to be distinguishable from strings.
+		s, quoted = formatText(fv, w.nullString()), false
+	case string:
+		s = formatText(fv, v)
+	case json.Number:
+		// Numbers and booleans left unchanged by fv keep their type, e.g. those not
+		// detected as epochs by DetectEpochs.
+		if fv == nil {
+			s = string(v)
+		} else {
+			s = fv(v)
+		}
+		quoted = !w.PreserveTypes || s != string(v)
+	case bool:
+		text := w.BoolFormat.Format(v)
+		s = formatText(fv, text)
+		quoted = !w.PreserveTypes || s != text
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			s = fmt.Sprintf("[error: %v]", err)
+		} else if fv == nil {
+			s = string(b)
+		} else {
+			s = fv(b)
+		}
+	}
+	return w.truncateValue(s), quoted
+}
+
+// formatText formats the string s with fv, or returns it unchanged if fv is nil.
+func formatText(fv Formatter, s string) string {
+	if fv == nil {
+		return s
+	}
+	return fv(s)
 }
 
 func defaultFormatValue(i interface{}) string {
+	return formatString(i)
+}
+
+// formatString formats i like fmt.Sprintf("%s", i), without reflection for the strings,
+// json.Number and JSON bytes passed by writeValue.
+func formatString(i interface{}) string {
+	switch v := i.(type) {
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	case []byte:
+		return string(v)
+	}
 	return fmt.Sprintf("%s", i)
 }
@@ -0,0 +1,149 @@
+package kvwriter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestWriteInvalidLines(t *testing.T) {
+	const in = "{\"msg\":\"ok\"}\nnot json\n{\"msg\":\"later\"}\n"
+	tests := []struct {
+		mode  ErrorMode
+		want  string
+		n     int
+		fails bool
+	}{
+		{ErrorPassThrough, "msg=\"ok\"\nnot json\nmsg=\"later\"\n", len(in), false},
+		{ErrorDrop, "msg=\"ok\"\nmsg=\"later\"\n", len(in), false},
+		{ErrorFail, "msg=\"ok\"\n", len("{\"msg\":\"ok\"}\n"), true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := NewKeyValueWriter(WithOutput(&out), WithErrorMode(tt.mode))
+		n, err := w.Write([]byte(in))
+
+		var de *DecodeError
+		if tt.fails != errors.As(err, &de) {
+			t.Errorf("mode %d: unexpected error %v", tt.mode, err)
+		} else if tt.fails && string(de.Input) != "not json" {
+			t.Errorf("mode %d: error input %q", tt.mode, de.Input)
+		}
+		if n != tt.n {
+			t.Errorf("mode %d: n = %d, want %d", tt.mode, n, tt.n)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("mode %d: got %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestWriteRenderError(t *testing.T) {
+	const in = "{\"a\":1}\n{\"x.y\":1,\"x\":{\"y\":2}}\n{\"b\":2}\n"
+	var out bytes.Buffer
+	w := NewKeyValueWriter(WithOutput(&out), WithCollisionMode(CollisionError))
+	n, err := w.Write([]byte(in))
+	if !errors.Is(err, ErrFlatten) {
+		t.Fatalf("error %v does not match ErrFlatten", err)
+	}
+	if n != len(in) {
+		t.Errorf("n = %d, want %d", n, len(in))
+	}
+	if got, want := out.String(), "a=\"1\"\nb=\"2\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNewInvalidConfiguration(t *testing.T) {
+	if _, err := New(WithLevelWidth(-1)); err == nil {
+		t.Error("no error for a negative level width")
+	}
+	if _, err := New(WithLevelWidth(4)); err != nil {
+		t.Errorf("valid configuration: %v", err)
+	}
+}
+
+func TestWithOptionsInvalidConfiguration(t *testing.T) {
+	w := NewKeyValueWriter(WithLevelWidth(4))
+	if _, err := w.WithOptions(WithLevelWidth(-1)); err == nil {
+		t.Error("no error for a negative level width")
+	}
+	c, err := w.WithOptions(WithLevelWidth(6))
+	if err != nil {
+		t.Fatalf("valid configuration: %v", err)
+	}
+	if c.LevelWidth != 6 || w.LevelWidth != 4 {
+		t.Errorf("got level widths %d and %d, want 6 and 4", c.LevelWidth, w.LevelWidth)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("With did not panic")
+		}
+	}()
+	w.With(WithLevelWidth(-1))
+}
+
+// BenchmarkWrite measures writing with the default formatters, which format strings,
+// numbers, booleans and nulls without calling a Formatter.
+func BenchmarkWrite(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		options []Option
+	}{
+		{"default", nil},
+		{"unquoted", []Option{WithQuoteValues(false)}},
+		{"preserve-types", []Option{WithPreserveTypes(true)}},
+	}
+	event := []byte(`{"time":"2024-05-01T12:00:00Z","level":"info","message":"request served",` +
+		`"status":200,"duration_ms":12.5,"cached":false,"user":null,"tags":["a","b"]}`)
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			w := NewKeyValueWriter(append(bm.options, WithOutput(io.Discard))...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := w.Write(event); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteKeepsDecoderEvents(t *testing.T) {
+	evt := map[string]interface{}{"msg": "kept"}
+	var out bytes.Buffer
+	w := NewKeyValueWriter(WithOutput(&out), WithDecoder(DecoderFunc(func([]byte) ([]map[string]interface{}, error) {
+		return []map[string]interface{}{evt}, nil
+	})))
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := out.String(), "msg=\"kept\"\nmsg=\"kept\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAfterWriteHook(t *testing.T) {
+	var out bytes.Buffer
+	var written []string
+	w := NewKeyValueWriter(WithOutput(&out), WithHooks(Hooks{
+		AfterWrite: func(output []byte, err error) {
+			written = append(written, string(output))
+		},
+	}))
+
+	if _, err := w.Write([]byte("{\"a\":1}\n{\"b\":2}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteEvent(map[string]interface{}{"c": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a=\"1\"\nb=\"2\"\n", "c=\"3\"\n"}; !slices.Equal(written, want) {
+		t.Errorf("got %q, want %q", written, want)
+	}
+}